@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ambiweb/osm-pbf-filter/pbf"
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// xmlStartSize is the size of the start of OSM XML files hashed for their
+// fingerprint.
+const xmlStartSize = 64 * 1024
+
+// fingerprint identifies the contents of input files by their sizes,
+// modification times and hashes of their first blobs, the OSMHeader with
+// e.g. the replication timestamp of planet files. Of OSM XML files, the
+// start is hashed instead.
+func fingerprint(files []string) (string, error) {
+	h := sha256.New()
+	for _, file := range files {
+		if err := fingerprintFile(h, file); err != nil {
+			return "", fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+func fingerprintFile(w io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d %d\n", fi.Size(), fi.ModTime().UnixNano())
+	if isOSMXML(file) {
+		_, err := io.CopyN(w, f, xmlStartSize)
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	header, err := pbf.ReadBlobHeader(io.TeeReader(f, w))
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(w, f, int64(header.GetDatasize()))
+	return err
+}
+
+// makeLevelDB opens the levelDB cache of the input files in the cache
+// directory, named after their fingerprint, so that a run on changed files
+// does not reuse data of an earlier one. With -no-cache levelDB is kept in
+// memory. The returned function closes levelDB and deletes it after a run
+// which succeeded, unless -keep-cache is set. The cache of a failed run is
+// kept, so that the next run resumes it.
+func makeLevelDB(ui *UI) (*leveldb.DB, func(ok bool) error, error) {
+	if ui.NoCache {
+		db, err := leveldb.Open(storage.NewMemStorage(), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := run.InitDB(db); err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return db, func(bool) error { return db.Close() }, nil
+	}
+	fp, err := fingerprint(ui.Args)
+	if err != nil {
+		return nil, nil, err
+	}
+	path := filepath.Join(ui.CacheDir, fp+".db")
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := run.InitDB(db); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	cleanup := func(ok bool) error {
+		if err := db.Close(); err != nil {
+			return err
+		}
+		if ui.KeepCache || !ok {
+			return nil
+		}
+		return os.RemoveAll(path)
+	}
+	return db, cleanup, nil
+}
+
+// makeTempLevelDB opens a levelDB in a new directory of the cache directory,
+// for the items collected by the two-pass strategy. With -no-cache levelDB is
+// kept in memory. The returned function closes levelDB and deletes it, as
+// there is nothing to resume.
+func makeTempLevelDB(ui *UI) (*leveldb.DB, func(ok bool) error, error) {
+	if ui.NoCache {
+		db, err := leveldb.Open(storage.NewMemStorage(), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, func(bool) error { return db.Close() }, nil
+	}
+	path, err := ioutil.TempDir(ui.CacheDir, "twopass")
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		os.RemoveAll(path)
+		return nil, nil, err
+	}
+	cleanup := func(bool) error {
+		if err := db.Close(); err != nil {
+			return err
+		}
+		return os.RemoveAll(path)
+	}
+	return db, cleanup, nil
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+)
+
+// runCat converts files to another output format, without filtering.
+func runCat(env Env) int {
+	fs := flag.NewFlagSet("cat", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	format := fs.String("format", run.FormatJSON, "")
+	columns := fs.String("csv-columns", "name", "")
+	if err := fs.Parse(env.Args[1:]); err != nil {
+		return parseError(env, err, catUsage)
+	}
+	w, dec, err := makeCat(env, *format, *columns, fs.Args())
+	if err != nil {
+		fmt.Fprintln(env.Stderr, err.Error())
+		return 2
+	}
+	code := 0
+	if err := run.Copy(w, dec); err != nil {
+		fmt.Fprintln(env.Stderr, err.Error())
+		code = 1
+	}
+	if err := dec.Close(); err != nil {
+		fmt.Fprintln(env.Stderr, err.Error())
+		code = 1
+	}
+	return code
+}
+
+func makeCat(env Env, format, columns string, files []string) (run.Writer, *run.MultiDecoder, error) {
+	if len(files) < 1 {
+		return nil, nil, errors.New(catUsage)
+	}
+	switch format {
+	case run.FormatJSON, run.FormatNDJSON, run.FormatCSV, run.FormatPBF, run.FormatOSM:
+	case run.FormatGeoJSON:
+		return nil, nil, errors.New("cat: geojson needs the nodes of ways, use filter")
+	default:
+		return nil, nil, fmt.Errorf("unknown output format %q", format)
+	}
+	dec, err := makePBFDecoder(files, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	// pbf and osm output take the header of the input from the decoder
+	c := &run.Command{PBFDecoder: dec, Stdout: env.Stdout}
+	if columns != "" {
+		c.CSVColumns = strings.Split(columns, ",")
+	}
+	w, err := c.NewWriter(format)
+	if err != nil {
+		return nil, nil, err
+	}
+	return w, dec, nil
+}
+
+const catUsage = `Usage:
+osm-pbf-filter cat [OPTIONS] FILE [FILE...]
+
+Writes every item of the files, PBF or OSM XML (.osm, .osm.bz2), in another
+format. Items are written as read, without cache; pbf output needs input
+sorted by type and ID, as PBF files usually are.
+
+Options:
+  -format Output format: json (default), ndjson, csv, pbf or osm, as for
+        filter, which keep the input header the same way.
+  -csv-columns Comma separated tags to write as csv columns. Default 'name'.
+`
@@ -0,0 +1,190 @@
+package cli_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/cli"
+	"github.com/ambiweb/osm-pbf-filter/pbf"
+	"github.com/qedus/osmpbf"
+)
+
+// writePBF writes a node and a way to a PBF file in dir.
+func writePBF(t *testing.T, dir string) string {
+	file := filepath.Join(dir, "input.pbf")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	enc := pbf.NewEncoder(f)
+	info := osmpbf.Info{Version: 2, Visible: true}
+	for _, v := range []interface{}{
+		&osmpbf.Node{ID: 1, Lat: 52.5, Lon: 13.4, Tags: map[string]string{"amenity": "cafe", "name": "Kaffee"}, Info: info},
+		&osmpbf.Way{ID: 2, NodeIDs: []int64{1, 1}, Tags: map[string]string{"highway": "footway"}, Info: info},
+	} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+var subcommandTests = []struct {
+	args     []string
+	code     int
+	expected []string // in stdout or stderr
+}{
+	{[]string{"info", "FILE"}, 0, []string{"writing program: osm-pbf-filter", "data blocks: 2"}},
+	{[]string{"info"}, 2, []string{"osm-pbf-filter info FILE.pbf"}},
+	{[]string{"cat", "-format", "csv", "FILE"}, 0, []string{"id,lat,lon,name\n1,52.5000000,13.4000000,Kaffee\n"}},
+	{[]string{"cat", "-format", "ndjson", "FILE"}, 0, []string{`"type":"node","id":1`, `"type":"way","id":2`}},
+	{[]string{"cat", "-format", "geojson", "FILE"}, 2, []string{"use filter"}},
+	{[]string{"stats", "-top", "1", "FILE"}, 0, []string{"3 keys, top 1:\n  amenity  1\n", "max version"}},
+	{[]string{"stats", "-unknown", "FILE"}, 2, []string{"-unknown"}},
+	{[]string{"filter", "-tags", "", "-strategy", "twopass", "-format", "ndjson", "FILE"}, 0, []string{`"id":1`, `"id":2`}},
+	{[]string{"-expr", "highway", "-format", "ndjson", "FILE"}, 0, []string{`"type":"way","id":2`}},
+	{[]string{"cat", "-h"}, 0, []string{"osm-pbf-filter cat [OPTIONS]"}},
+}
+
+func TestSubcommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := writePBF(t, dir)
+	for _, tt := range subcommandTests {
+		args := []string{"osm-pbf-filter"}
+		for _, arg := range tt.args {
+			args = append(args, strings.Replace(arg, "FILE", file, 1))
+		}
+		var stdout, stderr bytes.Buffer
+		code := cli.ParseAndRun(cli.Env{Args: args, Stdout: &stdout, Stderr: &stderr})
+		if code != tt.code {
+			t.Errorf("%v: expected code %d, actual %d: %s", tt.args, tt.code, code, stderr.String())
+			continue
+		}
+		out := stdout.String() + stderr.String()
+		for _, s := range tt.expected {
+			if !strings.Contains(out, s) {
+				t.Errorf("%v: expected %q in %q", tt.args, s, out)
+			}
+		}
+	}
+}
+
+var usageTests = []struct {
+	args       []string
+	expected   string
+	unexpected string
+}{
+	{[]string{"-h"}, "  -strategy", "  -diff"},
+	{[]string{"filter", "-h"}, "  -strategy", "  -diff"},
+	{[]string{"update", "-h"}, "  -diff", "  -strategy"},
+	{[]string{"update", "-strategy", "twopass", "FILE"}, "not defined: -strategy", "Usage:"},
+	{[]string{"filter", "-diff", "FILE"}, "not defined: -diff", "Usage:"},
+}
+
+func TestUsage(t *testing.T) {
+	for _, tt := range usageTests {
+		var stdout, stderr bytes.Buffer
+		cli.ParseAndRun(cli.Env{Args: append([]string{"osm-pbf-filter"}, tt.args...), Stdout: &stdout, Stderr: &stderr})
+		out := stderr.String()
+		if !strings.Contains(out, tt.expected) {
+			t.Errorf("%v: expected %q in %q", tt.args, tt.expected, out)
+		}
+		if strings.Contains(out, tt.unexpected) {
+			t.Errorf("%v: unexpected %q in %q", tt.args, tt.unexpected, out)
+		}
+	}
+}
+
+func TestParseChanges(t *testing.T) {
+	args := []string{"osm-pbf-filter", "update", "a.pbf", "b.osm.bz2", "c.osc", "d.osc.gz", "e.OSC.bz2"}
+	ui, err := cli.Parse(cli.Env{Args: args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"c.osc", "d.osc.gz", "e.OSC.bz2"}
+	if !reflect.DeepEqual(expected, ui.Changes) {
+		t.Errorf("Expected changes %v, actual %v", expected, ui.Changes)
+	}
+	expected = []string{"a.pbf", "b.osm.bz2"}
+	if !reflect.DeepEqual(expected, ui.Args) {
+		t.Errorf("Expected inputs %v, actual %v", expected, ui.Args)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := writePBF(t, dir)
+	for _, strategy := range []string{"db", "twopass"} {
+		args := []string{"osm-pbf-filter", "-quiet", "-no-cache", "-strategy", strategy, "-expr", "highway", "-format", "ndjson", file}
+		var stdout, stderr bytes.Buffer
+		if code := cli.ParseAndRun(cli.Env{Args: args, Stdout: &stdout, Stderr: &stderr}); code != 0 {
+			t.Fatalf("%s: expected code 0, actual %d: %s", strategy, code, stderr.String())
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("%s: expected no stderr output, actual %q", strategy, stderr.String())
+		}
+	}
+}
+
+func TestCacheKeptOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "missing.pbf")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := pbf.NewEncoder(f)
+	if err := enc.Encode(&osmpbf.Way{ID: 2, NodeIDs: []int64{1}, Tags: map[string]string{"highway": "footway"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	for _, tt := range []struct {
+		strategy string
+		missing  string
+		code     int
+		caches   int
+	}{
+		{"db", "fail", 1, 1},
+		{"db", "skip", 0, 0},
+		// the temporary levelDB of twopass is never kept
+		{"twopass", "fail", 1, 0},
+	} {
+		args := []string{"osm-pbf-filter", "-strategy", tt.strategy, "-cache-dir", dir, "-missing", tt.missing, "-expr", "highway", file}
+		var stdout, stderr bytes.Buffer
+		if code := cli.ParseAndRun(cli.Env{Args: args, Stdout: &stdout, Stderr: &stderr}); code != tt.code {
+			t.Fatalf("%s %s: expected code %d, actual %d: %s", tt.strategy, tt.missing, tt.code, code, stderr.String())
+		}
+		entries, err := filepath.Glob(filepath.Join(dir, "*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// besides the input
+		if caches := len(entries) - 1; caches != tt.caches {
+			t.Errorf("%s %s: expected %d caches, actual %d", tt.strategy, tt.missing, tt.caches, caches)
+		}
+	}
+}
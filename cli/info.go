@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ambiweb/osm-pbf-filter/pbf"
+)
+
+// runInfo prints the header and block counts of PBF files.
+func runInfo(env Env) int {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(env.Args[1:]); err != nil {
+		return parseError(env, err, infoUsage)
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprint(env.Stderr, infoUsage)
+		return 2
+	}
+	for i, file := range fs.Args() {
+		if i > 0 {
+			fmt.Fprintln(env.Stdout)
+		}
+		info, err := readInfo(file)
+		if err != nil {
+			fmt.Fprintln(env.Stderr, err.Error())
+			return 1
+		}
+		writeInfo(env.Stdout, file, info)
+	}
+	return 0
+}
+
+func readInfo(file string) (*pbf.Info, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := pbf.ReadInfo(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return info, nil
+}
+
+// writeInfo writes the fields the file has, one per line.
+func writeInfo(w io.Writer, file string, info *pbf.Info) {
+	fmt.Fprintf(w, "file: %s\n", file)
+	if b := info.BoundingBox; b != nil {
+		fmt.Fprintf(w, "bbox: %.7f,%.7f,%.7f,%.7f\n", b.Left, b.Bottom, b.Right, b.Top)
+	}
+	if len(info.RequiredFeatures) > 0 {
+		fmt.Fprintf(w, "required features: %s\n", strings.Join(info.RequiredFeatures, ", "))
+	}
+	if len(info.OptionalFeatures) > 0 {
+		fmt.Fprintf(w, "optional features: %s\n", strings.Join(info.OptionalFeatures, ", "))
+	}
+	if info.WritingProgram != "" {
+		fmt.Fprintf(w, "writing program: %s\n", info.WritingProgram)
+	}
+	if info.Source != "" {
+		fmt.Fprintf(w, "source: %s\n", info.Source)
+	}
+	if !info.OsmosisReplicationTimestamp.IsZero() {
+		fmt.Fprintf(w, "replication timestamp: %s\n", info.OsmosisReplicationTimestamp.UTC().Format(time.RFC3339))
+	}
+	if info.OsmosisReplicationSequenceNumber != 0 {
+		fmt.Fprintf(w, "replication sequence number: %d\n", info.OsmosisReplicationSequenceNumber)
+	}
+	if info.OsmosisReplicationBaseUrl != "" {
+		fmt.Fprintf(w, "replication base URL: %s\n", info.OsmosisReplicationBaseUrl)
+	}
+	fmt.Fprintf(w, "header blocks: %d\n", info.HeaderBlocks)
+	fmt.Fprintf(w, "data blocks: %d\n", info.DataBlocks)
+}
+
+const infoUsage = `Usage:
+osm-pbf-filter info FILE.pbf [FILE.pbf...]
+
+Prints the header of PBF files: bounding box, required and optional
+features, writing program, source and replication state, and the number of
+blocks. Data blocks are skipped, not decoded.
+`
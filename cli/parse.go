@@ -1,22 +1,27 @@
 package cli
 
 import (
-	"crypto/md5"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 
 	yaml "gopkg.in/yaml.v2"
 
+	"github.com/ambiweb/osm-pbf-filter/geo"
+	"github.com/ambiweb/osm-pbf-filter/osmxml"
 	"github.com/ambiweb/osm-pbf-filter/run"
 	"github.com/ambiweb/osm-pbf-filter/tags"
 	"github.com/qedus/osmpbf"
-	"github.com/syndtr/goleveldb/leveldb"
 )
 
 // Env encapsulates command environment.
@@ -26,112 +31,632 @@ type Env struct {
 	Stderr io.Writer
 }
 
-// ParseAndRun parses the environment to create a run.Command and runs it. It returns
-// the code that should be used for os.Exit.
+// subcommands other than filter and update, which share their options. Each
+// gets the environment with its name as first argument.
+var subcommands = map[string]func(env Env) int{
+	"info":  runInfo,
+	"cat":   runCat,
+	"stats": runStats,
+}
+
+// ParseAndRun parses the environment to run the subcommand it names, filter
+// by default. It returns the code that should be used for os.Exit.
 func ParseAndRun(env Env) int {
+	if len(env.Args) > 1 {
+		if fn, ok := subcommands[env.Args[1]]; ok {
+			return fn(Env{Args: env.Args[1:], Stdout: env.Stdout, Stderr: env.Stderr})
+		}
+	}
+	return runFilter(env)
+}
+
+// runFilter parses the environment to create a run.Command and runs it.
+func runFilter(env Env) int {
 	ui, err := Parse(env)
-	if err == flag.ErrHelp {
-		fmt.Fprintln(env.Stderr, usage)
-		return 0
+	if err != nil {
+		return parseError(env, err, ui.usage())
 	}
+	c, cleanup, err := makeCommand(ui, env)
 	if err != nil {
 		fmt.Fprintln(env.Stderr, err.Error())
 		return 2
 	}
-	c, err := makeCommand(ui, env)
+	runFunc := run.Run
+	if ui.Update {
+		runFunc = run.Update
+	}
+	log.SetOutput(c.Progress.LogWriter(env.Stderr))
+	defer log.SetOutput(os.Stderr)
+	code := 0
+	err = runFunc(c)
+	// ends a progress bar before errors are printed
+	c.Progress.Close()
 	if err != nil {
 		fmt.Fprintln(env.Stderr, err.Error())
-		return 2
+		code = 1
 	}
-	if err := run.Run(c); err != nil {
+	if err := cleanup(code == 0); err != nil {
 		fmt.Fprintln(env.Stderr, err.Error())
-		return 1
+		code = 1
 	}
-	return 0
+	return code
+}
+
+// parseError prints the usage on -h, the error otherwise, and returns the
+// exit code.
+func parseError(env Env, err error, usage string) int {
+	if err == flag.ErrHelp {
+		fmt.Fprint(env.Stderr, usage)
+		return 0
+	}
+	fmt.Fprintln(env.Stderr, err.Error())
+	return 2
 }
 
 // UI represents the UI of the CLI.
 type UI struct {
-	TagsFile string
-	Args     []string
+	TagsFile   string
+	Expr       string
+	Split      bool
+	IDs        string
+	IDsFile    string
+	BBox       string
+	Polygon    string
+	Closure    string
+	Missing    string
+	Strategy   string
+	CacheDir   string
+	KeepCache  bool
+	NoCache    bool
+	Format     string
+	CSVColumns string
+	Update     bool // update subcommand
+	Diff       bool
+	Progress   string
+	Quiet      bool
+	Args       []string
+	Changes    []string // OsmChange files of the update subcommand
 }
 
-// Parse converts the program command line.
+// usage returns the usage of the subcommand of ui.
+func (ui *UI) usage() string {
+	if ui.Update {
+		return updateUsage
+	}
+	return usage
+}
+
+// Parse converts the command line of the filter and update subcommands.
+// Each has its own flags: -strategy is filter's, -diff update's. On errors
+// the returned UI still tells the subcommand.
 func Parse(env Env) (*UI, error) {
 	ui := &UI{}
+	args := env.Args[1:]
+	if len(args) > 0 && (args[0] == "filter" || args[0] == "update") {
+		ui.Update = args[0] == "update"
+		args = args[1:]
+	}
 	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
 	fs.StringVar(&ui.TagsFile, "tags", "tags.yaml", "")
-	if err := fs.Parse(env.Args[1:]); err != nil {
-		return nil, err
+	fs.StringVar(&ui.Expr, "expr", "", "")
+	fs.BoolVar(&ui.Split, "split-values", false, "")
+	fs.StringVar(&ui.IDs, "ids", "", "")
+	fs.StringVar(&ui.IDsFile, "ids-file", "", "")
+	fs.StringVar(&ui.BBox, "bbox", "", "")
+	fs.StringVar(&ui.Polygon, "polygon", "", "")
+	fs.StringVar(&ui.Closure, "closure", run.ClosureCompleteWays, "")
+	fs.StringVar(&ui.Missing, "missing", run.MissingWarn, "")
+	fs.StringVar(&ui.CacheDir, "cache-dir", ".", "")
+	fs.BoolVar(&ui.KeepCache, "keep-cache", false, "")
+	fs.BoolVar(&ui.NoCache, "no-cache", false, "")
+	fs.StringVar(&ui.Format, "format", run.FormatJSON, "")
+	fs.StringVar(&ui.CSVColumns, "csv-columns", "name", "")
+	fs.StringVar(&ui.Progress, "progress", progressAuto, "")
+	fs.BoolVar(&ui.Quiet, "quiet", false, "")
+	if ui.Update {
+		// changes are applied to the cache
+		ui.Strategy = run.StrategyDB
+		fs.BoolVar(&ui.Diff, "diff", false, "")
+	} else {
+		fs.StringVar(&ui.Strategy, "strategy", strategyAuto, "")
+	}
+	if err := fs.Parse(args); err != nil {
+		return ui, err
+	}
+	if ui.IDs != "" || ui.IDsFile != "" {
+		// IDs select items alone unless a tags filter is given
+		tagsSet := false
+		fs.Visit(func(f *flag.Flag) {
+			tagsSet = tagsSet || f.Name == "tags"
+		})
+		if !tagsSet {
+			ui.TagsFile = ""
+		}
+	}
+	if !ui.Update {
+		ui.Args = fs.Args()
+		return ui, nil
+	}
+	for _, arg := range fs.Args() {
+		if isOSC(arg) {
+			ui.Changes = append(ui.Changes, arg)
+		} else {
+			ui.Args = append(ui.Args, arg)
+		}
 	}
-	ui.Args = fs.Args()
 	return ui, nil
 }
 
-func makeCommand(ui *UI, env Env) (cmd *run.Command, err error) {
+// makeCommand returns the command and a function to clean up after it ran,
+// telling if it succeeded.
+func makeCommand(ui *UI, env Env) (cmd *run.Command, cleanup func(ok bool) error, err error) {
+	cmd, err = makeRunCommand(ui, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cmd.Strategy == run.StrategyTwoPass {
+		// the two-pass strategy stores only the items it collects
+		cmd.LevelDB, cleanup, err = makeTempLevelDB(ui)
+	} else {
+		cmd.LevelDB, cleanup, err = makeLevelDB(ui)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return cmd, cleanup, nil
+}
+
+func makeRunCommand(ui *UI, env Env) (cmd *run.Command, err error) {
 	if len(ui.Args) < 1 {
-		return nil, errors.New(usage)
+		return nil, errors.New(ui.usage())
+	}
+	if ui.KeepCache && ui.NoCache {
+		return nil, errors.New("-keep-cache and -no-cache exclude each other")
 	}
-	cmd = &run.Command{Stdout: env.Stdout}
-	if cmd.PBFDecoder, err = makePBFDecoder(ui.Args); err != nil {
+	if ui.Update {
+		if len(ui.Changes) == 0 {
+			return nil, errors.New("update: no change files")
+		}
+		if ui.NoCache {
+			return nil, errors.New("update: changes are applied to the cache, it can not be left out")
+		}
+		ui.Strategy = run.StrategyDB
+		ui.KeepCache = true
+	}
+	switch ui.Format {
+	case run.FormatJSON, run.FormatNDJSON, run.FormatCSV, run.FormatPBF, run.FormatOSM, run.FormatGeoJSON:
+	default:
+		return nil, fmt.Errorf("unknown output format %q", ui.Format)
+	}
+	switch ui.Closure {
+	case run.ClosureSimple, run.ClosureCompleteWays, run.ClosureSmart:
+	default:
+		return nil, fmt.Errorf("unknown closure strategy %q", ui.Closure)
+	}
+	switch ui.Missing {
+	case run.MissingSkip, run.MissingWarn, run.MissingFail:
+	default:
+		return nil, fmt.Errorf("unknown missing member policy %q", ui.Missing)
+	}
+	switch ui.Strategy {
+	case strategyAuto, run.StrategyDB, run.StrategyTwoPass:
+	default:
+		return nil, fmt.Errorf("unknown strategy %q", ui.Strategy)
+	}
+	switch ui.Progress {
+	case progressAuto, run.ProgressLog, run.ProgressBar, run.ProgressJSON:
+	default:
+		return nil, fmt.Errorf("unknown progress mode %q", ui.Progress)
+	}
+	cmd = &run.Command{
+		Dedupe:  len(ui.Args) > 1,
+		Closure: ui.Closure,
+		Missing: ui.Missing,
+		Format:  ui.Format,
+		Stdout:  env.Stdout,
+	}
+	if ui.CSVColumns != "" {
+		cmd.CSVColumns = strings.Split(ui.CSVColumns, ",")
+	}
+	if cmd.Progress, err = makeProgress(ui, env); err != nil {
 		return nil, err
 	}
-	if cmd.LevelDB, err = makeLevelDB(ui.Args); err != nil {
+	dec, err := makePBFDecoder(ui.Args, cmd.Progress)
+	if err != nil {
 		return nil, err
 	}
-	if cmd.TagsMatcher, err = makeTagsMatcher(ui.TagsFile); err != nil {
+	// decoders of cmd.Reopen read the same headers again
+	dec.LogHeaders = true
+	cmd.PBFDecoder = dec
+	if ui.Update {
+		if cmd.Changes, err = makeChangeDecoder(ui.Changes); err != nil {
+			return nil, err
+		}
+		cmd.Diff = ui.Diff
+	}
+	cmd.Reopen = func() (run.Decoder, error) {
+		return makePBFDecoder(ui.Args, cmd.Progress)
+	}
+	if cmd.TagsMatcher, err = makeTagsMatcher(ui.TagsFile, ui.Expr); err != nil {
+		return nil, err
+	}
+	if ui.Split && cmd.TagsMatcher != nil {
+		tags.SplitValues(cmd.TagsMatcher)
+	}
+	if cmd.IDs, err = makeIDs(ui.IDs, ui.IDsFile); err != nil {
 		return nil, err
 	}
+	if cmd.Region, err = makeRegion(ui.BBox, ui.Polygon); err != nil {
+		return nil, err
+	}
+	cmd.Strategy = chooseStrategy(ui, cmd)
+	if cmd.Strategy == run.StrategyTwoPass && cmd.Region != nil {
+		return nil, errors.New("strategy twopass does not support region filters")
+	}
 
 	return cmd, nil
 }
 
-func makePBFDecoder(files []string) (*osmpbf.Decoder, error) {
-	rs := make([]io.Reader, len(files))
+// strategyAuto chooses a strategy for the command.
+const strategyAuto = "auto"
+
+// chooseStrategy resolves strategyAuto. Reading the input twice pays off
+// when a tags filter or IDs select items of a single input. Region filters,
+// reading several inputs and keeping the cache need levelDB.
+func chooseStrategy(ui *UI, cmd *run.Command) string {
+	if ui.Strategy != strategyAuto {
+		return ui.Strategy
+	}
+	if (cmd.TagsMatcher != nil || cmd.IDs != nil) && cmd.Region == nil && !cmd.Dedupe && !ui.KeepCache {
+		return run.StrategyTwoPass
+	}
+	return run.StrategyDB
+}
+
+// progressAuto chooses a progress mode for the command.
+const progressAuto = "auto"
+
+// makeProgress returns the Progress of the command: none with -quiet, a bar
+// if stderr is a terminal and log lines otherwise, unless -progress tells.
+func makeProgress(ui *UI, env Env) (*run.Progress, error) {
+	mode := ui.Progress
+	switch {
+	case ui.Quiet:
+		mode = run.ProgressNone
+	case mode == progressAuto && isTerminal(env.Stderr):
+		mode = run.ProgressBar
+	case mode == progressAuto:
+		mode = run.ProgressLog
+	}
+	var total int64
+	for _, s := range ui.Args {
+		fi, err := os.Stat(s)
+		if err != nil {
+			return nil, err
+		}
+		total += fi.Size()
+	}
+	return run.NewProgress(env.Stderr, mode, total), nil
+}
+
+// isTerminal tells if w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// makePBFDecoder decodes the files in turn, each with its own decoder:
+// osmpbf.Decoder for PBF files, osmxml.Decoder for .osm and .osm.bz2 files.
+// Input read is counted by p, which may be nil.
+func makePBFDecoder(files []string, p *run.Progress) (*run.MultiDecoder, error) {
+	inputs := make([]run.OpenFunc, len(files))
 	for i, s := range files {
-		f, err := os.Open(s)
+		// fail early on files that can not be opened
+		if _, err := os.Stat(s); err != nil {
+			return nil, err
+		}
+		if isOSMXML(s) {
+			inputs[i] = openOSMXML(s, p)
+		} else {
+			inputs[i] = openPBF(s, p)
+		}
+	}
+	return run.NewMultiDecoder(inputs...), nil
+}
+
+// pbfFile is a PBF file being decoded.
+type pbfFile struct {
+	*osmpbf.Decoder
+	f *os.File
+}
+
+func (pf *pbfFile) Close() error {
+	return pf.f.Close()
+}
+
+func openPBF(file string, p *run.Progress) run.OpenFunc {
+	return func() (run.Decoder, error) {
+		f, err := os.Open(file)
 		if err != nil {
 			return nil, err
 		}
-		rs[i] = f
+		dec := osmpbf.NewDecoder(p.CountReader(f))
+		// use more memory from the start, it is faster
+		dec.SetBufferSize(osmpbf.MaxBlobSize)
+		// start decoding with several goroutines, it is faster
+		if err := dec.Start(runtime.GOMAXPROCS(-1)); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		return &pbfFile{dec, f}, nil
 	}
-	pbfReader := io.MultiReader(rs...)
-	dec := osmpbf.NewDecoder(pbfReader)
-	// use more memory from the start, it is faster
-	dec.SetBufferSize(osmpbf.MaxBlobSize)
-	// start decoding with several goroutines, it is faster
-	if err := dec.Start(runtime.GOMAXPROCS(-1)); err != nil {
-		return nil, err
+}
+
+// makeChangeDecoder decodes the OsmChange files in turn.
+func makeChangeDecoder(files []string) (*run.MultiDecoder, error) {
+	inputs := make([]run.OpenFunc, len(files))
+	for i, s := range files {
+		if _, err := os.Stat(s); err != nil {
+			return nil, err
+		}
+		inputs[i] = openOSMXML(s, nil)
 	}
-	return dec, nil
+	return run.NewMultiDecoder(inputs...), nil
 }
 
-func makeLevelDB(files []string) (*leveldb.DB, error) {
-	data := []byte(strings.Join(files, ""))
-	path := fmt.Sprintf("%x.db", md5.Sum(data))
-	db, err := leveldb.OpenFile(path, nil)
-	if err != nil {
-		return nil, err
+// isOSC tells if a file is an OsmChange file, by its extension.
+func isOSC(file string) bool {
+	file = strings.ToLower(file)
+	return strings.HasSuffix(file, ".osc") || strings.HasSuffix(file, ".osc.gz") || strings.HasSuffix(file, ".osc.bz2")
+}
+
+// isOSMXML tells if a file is OSM XML, by its extension.
+func isOSMXML(file string) bool {
+	file = strings.ToLower(file)
+	return strings.HasSuffix(file, ".osm") || strings.HasSuffix(file, ".osm.bz2")
+}
+
+// xmlFile is an OSM XML file being decoded.
+type xmlFile struct {
+	*osmxml.Decoder
+	f *os.File
+}
+
+func (xf *xmlFile) Close() error {
+	return xf.f.Close()
+}
+
+func openOSMXML(file string, p *run.Progress) run.OpenFunc {
+	return func() (run.Decoder, error) {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		var r io.Reader = bufio.NewReader(p.CountReader(f))
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".bz2":
+			r = bzip2.NewReader(r)
+		case ".gz":
+			if r, err = gzip.NewReader(r); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+		}
+		return &xmlFile{osmxml.NewDecoder(r), f}, nil
 	}
-	return db, nil
 }
 
-func makeTagsMatcher(file string) (tags.Matcher, error) {
-	var tagsMatcher tags.Matcher
+// makeTagsMatcher compiles the -expr expression if it is given, the tags file
+// otherwise. The tags file is either a map of rules or a single expression
+// string. Without both there is no tags filter.
+func makeTagsMatcher(file, expr string) (tags.Expr, error) {
+	if expr != "" {
+		return tags.ParseExpr(expr)
+	}
+	if file == "" {
+		return nil, nil
+	}
 	b, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
-	if err := yaml.Unmarshal(b, &tagsMatcher); err != nil {
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
 		return nil, err
 	}
+	if s, ok := doc.(string); ok {
+		x, err := tags.ParseExpr(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		return x, nil
+	}
+	tagsMatcher := &tags.Matcher{}
+	if err := yaml.Unmarshal(b, tagsMatcher); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
 	return tagsMatcher, nil
 }
 
+// makeIDs reads the IDs of -ids and -ids-file. Without both there are no
+// IDs.
+func makeIDs(list, file string) (*run.IDs, error) {
+	if list == "" && file == "" {
+		return nil, nil
+	}
+	ids := &run.IDs{}
+	if err := ids.Parse(list); err != nil {
+		return nil, fmt.Errorf("-ids: %v", err)
+	}
+	if file == "" {
+		return ids, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := ids.Read(f); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return ids, nil
+}
+
+// makeRegion combines the -bbox and -polygon regions. Without both there is
+// no region filter.
+func makeRegion(bbox, polygon string) (geo.Region, error) {
+	var region geo.All
+	if bbox != "" {
+		b, err := geo.ParseBBox(bbox)
+		if err != nil {
+			return nil, err
+		}
+		region = append(region, b)
+	}
+	if polygon != "" {
+		p, err := geo.ReadPolygon(polygon)
+		if err != nil {
+			return nil, err
+		}
+		region = append(region, p)
+	}
+	switch len(region) {
+	case 0:
+		return nil, nil
+	case 1:
+		return region[0], nil
+	}
+	return region, nil
+}
+
+// usage is the usage of filter, also shown without subcommand.
 const usage = `Usage:
-osm-pbf-filter [OPTIONS] FILE.pbf
+osm-pbf-filter [filter] [OPTIONS] FILE.pbf [FILE.pbf...]
+osm-pbf-filter update [OPTIONS] FILE.pbf [FILE.pbf...] CHANGES.osc [CHANGES.osc...]
+osm-pbf-filter info FILE.pbf [FILE.pbf...]
+osm-pbf-filter cat [OPTIONS] FILE [FILE...]
+osm-pbf-filter stats [OPTIONS] FILE [FILE...]
+
+filter, the default, extracts matching items and those they need. update,
+info, cat and stats have their own options; see osm-pbf-filter SUBCOMMAND -h.
+
+Several files, e.g. neighbouring country extracts, are read in turn. Items in
+more than one of them are kept in their newest version. Files ending in .osm
+or .osm.bz2 are read as OSM XML.
 
 Options:
-  -tags YAML file with tags to match specified. Default 'tags.yaml' in current
-        directory.
+` + selectOptions + `  -strategy How to select items: db stores the whole input in levelDB,
+        twopass keeps IDs of selected items and of relations in memory and
+        reads the input again, writing only the extract to a temporary
+        levelDB, which pays off for extracts much smaller than the input.
+        It takes another pass for each level of relations selected by
+        relations after them, and one for smart.
+        twopass can not filter by region. auto (default) takes twopass for
+        a tags filter or IDs on a single input without region filter.
+` + cacheOptions + outputOptions
+
+// updateUsage is the usage of update.
+const updateUsage = `Usage:
+osm-pbf-filter update [OPTIONS] FILE.pbf [FILE.pbf...] CHANGES.osc [CHANGES.osc...]
+
+update applies OsmChange files (.osc, .osc.gz or .osc.bz2) in turn to the
+cache of the input files and outputs the updated extract. The cache is kept
+for the next update; it is made first if there is none. Use the filter
+options of the run which made the cache. filter refuses a cache changed by
+update, as the input files do not have the changes.
+
+Options:
+` + selectOptions + cacheOptions + `  -diff  Output the changes of the extract as OsmChange instead of the
+        updated extract.
+` + outputOptions
+
+// selectOptions are the options of filter and update selecting items.
+const selectOptions = `  -tags YAML file with tags to match specified. Default 'tags.yaml' in
+        current directory. Rules apply to nodes, ways and relations; prefix
+        a key with a combination of n/, w/ and r/ to limit it, e.g.
+        n/amenity. A map of comparisons matches numbers, with lt, lte, gt
+        and gte, e.g. 'admin_level: {lte: 4}' or
+        'population: {gte: 100000, lt: 1000000}'. Values may have a
+        decimal comma and a unit, e.g. '30 mph', converted to metres, km/h
+        or tonnes; a range such as '2-4' matches if any value in it does.
+        Values that are not numbers do not match. Other maps take one of
+        eq (a value or list), glob (with * and ?) or regex, e.g.
+        'highway: {regex: "^(primary|secondary)(_link)?$"}', and
+        ignore_case: yes to fold case. A key with * or ? matches keys,
+        e.g. 'name:*: Wien'. Invalid patterns are reported with their key.
+        split: yes matches values holding several, e.g.
+        cuisine=pizza;burger, by any of their parts, as -split-values does
+        for every rule. The keys @user, @uid, @changeset, @version and
+        @timestamp match metadata, e.g. '"@uid": [123, 456]' or
+        '"@timestamp": {gte: 2026-01-01}'; items without metadata do not
+        have them. The file may also hold a single expression string, see
+        -expr. Set it to '' to match every item, e.g. to filter by region
+        only.
+  -expr Tags filter expression, used instead of -tags. Terms are key,
+        key=value, key=v1,v2, key!=value, key=* and key!=*, comparisons
+        key<n, key<=n, key>n and key>=n read as in -tags, and regular
+        expressions key~"re" and key!~"re". Keys may be globs such as
+        addr:* or metadata keys such as @version or @timestamp. Values are
+        compared case-sensitively; only regular expressions fold case,
+        with (?i). Combine terms with and, or, not and parentheses, e.g.
+        'amenity=cafe and cuisine=coffee_shop or highway and not
+        highway=footway'.
+  -split-values Split tag values on ';' and match if any part does, so
+        cuisine=pizza matches cuisine=pizza;burger. ';;' is a semicolon.
+  -ids   Select items by ID, separated by commas, with n, w or r for their
+        type, e.g. r62422,w12345,n1. Their related items are added as for
+        matching ones. Without -tags or -expr only these are selected;
+        with them, items matching either are.
+  -ids-file File with IDs as for -ids, any number per line. Text after #
+        is a comment. Can be combined with -ids.
+  -bbox  Only match items inside minlon,minlat,maxlon,maxlat. A way is
+        inside if any of its nodes is, a relation if any of its members is.
+  -polygon Only match items inside the polygon of a GeoJSON or an Osmosis
+        .poly file, the same way as -bbox.
+  -closure Which related items to add to matched ones, after osmium extract
+        strategies: simple (nodes of ways, members of relations),
+        complete_ways (default, also nodes of member ways) or smart (also
+        multipolygon relations of matched ways with all their members).
+  -missing What to do with related items missing in the input, as in
+        regional extracts: skip, warn (default, log each one) or fail.
+        Missing items are summarized at the end of the run.
+`
+
+// cacheOptions are the options of filter and update for the levelDB cache.
+const cacheOptions = `  -cache-dir Directory for the levelDB cache. Default current directory.
+        The cache is named after a fingerprint of the input files: their
+        sizes, modification times and headers. twopass writes its
+        temporary levelDB there too.
+  -keep-cache Keep the cache after the run. A later run on the same input
+        reuses the stored data with its own filters. A run that failed or
+        was interrupted keeps the cache in any case, and the next run
+        resumes it.
+  -no-cache Keep levelDB in memory instead of on disk.
+`
+
+// outputOptions are the options of filter and update for output and
+// progress.
+const outputOptions = `  -format Output format: json (default), ndjson, csv, pbf, osm or
+        geojson. ndjson writes a line per item with the fields type, id,
+        tags, lat, lon, nodes, members and info. csv writes nodes with the
+        columns id, lat, lon and those of -csv-columns. pbf writes an OSM
+        PBF file sorted by type and ID. osm writes OSM XML, as JOSM reads
+        it. Both keep the bounding box of the input header; pbf also keeps
+        its source and replication state. geojson writes a
+        FeatureCollection with geometries assembled from the nodes and ways
+        in the input.
+  -csv-columns Comma separated tags to write as csv columns. Default 'name'.
+  -progress How to report progress on stderr: bar redraws a line with the
+        current phase, items per second, input read and time left; json
+        writes that state as a JSON line every second; log logs the start
+        of each phase. A summary of items read, matched and collected as
+        related, by type, and phase timings ends the run. auto (default)
+        takes bar if stderr is a terminal, log otherwise. none reports
+        nothing, as -quiet.
+  -quiet No progress, summary or log messages. Errors are still printed.
 `
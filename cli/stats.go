@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+)
+
+// runStats prints counts of the items in files.
+func runStats(env Env) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	top := fs.Int("top", 10, "")
+	if err := fs.Parse(env.Args[1:]); err != nil {
+		return parseError(env, err, statsUsage)
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprint(env.Stderr, statsUsage)
+		return 2
+	}
+	if *top < 0 {
+		fmt.Fprintln(env.Stderr, "-top must not be negative")
+		return 2
+	}
+	dec, err := makePBFDecoder(fs.Args(), nil)
+	if err != nil {
+		fmt.Fprintln(env.Stderr, err.Error())
+		return 2
+	}
+	defer dec.Close()
+	stats, err := run.ReadStats(dec)
+	if err == nil {
+		err = stats.WriteTo(env.Stdout, *top)
+	}
+	if err != nil {
+		fmt.Fprintln(env.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
+
+const statsUsage = `Usage:
+osm-pbf-filter stats [OPTIONS] FILE [FILE...]
+
+Counts the nodes, ways and relations of the files, PBF or OSM XML (.osm,
+.osm.bz2), with their tags and versions, and the most used tag keys.
+Items in more than one file are counted in each.
+
+Options:
+  -top  Number of tag keys to list, most used first. Default 10.
+`
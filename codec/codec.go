@@ -0,0 +1,321 @@
+// Package codec encodes OSM entities as compact binary records to store them
+// in levelDB instead of JSON.
+//
+// Integers are varints, zigzag encoded if they can be negative. Coordinates
+// are fixed-point integers in units of 100 nanodegrees, as in PBF files. Way
+// node refs and relation member IDs are delta encoded. Tag keys, tag values
+// and member roles are interned: frequent strings are replaced by their
+// index in a built-in table.
+//
+// Records made by Append start with a byte telling the entity type, so Decode
+// returns a *osmpbf.Node, *osmpbf.Way or *osmpbf.Relation without further
+// context.
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/qedus/osmpbf"
+)
+
+// ErrCorrupt is returned when a record can not be decoded.
+var ErrCorrupt = errors.New("codec: corrupt record")
+
+// info flags
+const (
+	flagVisible = 1 << iota
+	flagTimestamp
+)
+
+// Append appends the type tagged record of a Node, Way or Relation to b and
+// returns the result.
+func Append(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case *osmpbf.Node:
+		return AppendNode(append(b, byte(osmpbf.NodeType)), v), nil
+	case *osmpbf.Way:
+		return AppendWay(append(b, byte(osmpbf.WayType)), v), nil
+	case *osmpbf.Relation:
+		return AppendRelation(append(b, byte(osmpbf.RelationType)), v), nil
+	}
+	return nil, fmt.Errorf("codec: unknown type %T", v)
+}
+
+// Decode decodes a record made by Append into a *osmpbf.Node, *osmpbf.Way or
+// *osmpbf.Relation.
+func Decode(b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, ErrCorrupt
+	}
+	switch osmpbf.MemberType(b[0]) {
+	case osmpbf.NodeType:
+		return DecodeNode(b[1:])
+	case osmpbf.WayType:
+		return DecodeWay(b[1:])
+	case osmpbf.RelationType:
+		return DecodeRelation(b[1:])
+	}
+	return nil, ErrCorrupt
+}
+
+// AppendNode appends the record of a node to b and returns the result.
+func AppendNode(b []byte, n *osmpbf.Node) []byte {
+	b = appendVarint(b, n.ID)
+	b = appendVarint(b, coordinate(n.Lat))
+	b = appendVarint(b, coordinate(n.Lon))
+	b = appendTags(b, n.Tags)
+	return appendInfo(b, n.Info)
+}
+
+// AppendWay appends the record of a way to b and returns the result.
+func AppendWay(b []byte, w *osmpbf.Way) []byte {
+	b = appendVarint(b, w.ID)
+	b = appendTags(b, w.Tags)
+	b = appendInfo(b, w.Info)
+	b = appendUvarint(b, uint64(len(w.NodeIDs)))
+	var prev int64
+	for _, id := range w.NodeIDs {
+		b = appendVarint(b, id-prev)
+		prev = id
+	}
+	return b
+}
+
+// AppendRelation appends the record of a relation to b and returns the result.
+func AppendRelation(b []byte, r *osmpbf.Relation) []byte {
+	b = appendVarint(b, r.ID)
+	b = appendTags(b, r.Tags)
+	b = appendInfo(b, r.Info)
+	b = appendUvarint(b, uint64(len(r.Members)))
+	var prev int64
+	for _, m := range r.Members {
+		b = append(b, byte(m.Type))
+		b = appendVarint(b, m.ID-prev)
+		prev = m.ID
+		b = appendString(b, m.Role)
+	}
+	return b
+}
+
+// DecodeNode decodes a record made by AppendNode.
+func DecodeNode(b []byte) (*osmpbf.Node, error) {
+	d := decoder{b: b}
+	n := &osmpbf.Node{ID: d.varint()}
+	n.Lat = degrees(d.varint())
+	n.Lon = degrees(d.varint())
+	n.Tags = d.tags()
+	n.Info = d.info()
+	return n, d.done()
+}
+
+// DecodeWay decodes a record made by AppendWay.
+func DecodeWay(b []byte) (*osmpbf.Way, error) {
+	d := decoder{b: b}
+	w := &osmpbf.Way{ID: d.varint()}
+	w.Tags = d.tags()
+	w.Info = d.info()
+	if n := d.count(); n > 0 {
+		w.NodeIDs = make([]int64, n)
+		var id int64
+		for i := range w.NodeIDs {
+			id += d.varint()
+			w.NodeIDs[i] = id
+		}
+	}
+	return w, d.done()
+}
+
+// DecodeRelation decodes a record made by AppendRelation.
+func DecodeRelation(b []byte) (*osmpbf.Relation, error) {
+	d := decoder{b: b}
+	r := &osmpbf.Relation{ID: d.varint()}
+	r.Tags = d.tags()
+	r.Info = d.info()
+	if n := d.count(); n > 0 {
+		r.Members = make([]osmpbf.Member, n)
+		var id int64
+		for i := range r.Members {
+			m := &r.Members[i]
+			m.Type = osmpbf.MemberType(d.byte())
+			id += d.varint()
+			m.ID = id
+			m.Role = d.string()
+		}
+	}
+	return r, d.done()
+}
+
+// coordinate converts degrees to units of 100 nanodegrees.
+func coordinate(deg float64) int64 {
+	return int64(math.Floor(deg*1e7 + 0.5))
+}
+
+// degrees converts units of 100 nanodegrees to degrees the same way
+// osmpbf.Decoder does, so decoded values equal the ones read from PBF.
+func degrees(c int64) float64 {
+	return 1e-9 * float64(100*c)
+}
+
+func appendTags(b []byte, tags map[string]string) []byte {
+	b = appendUvarint(b, uint64(len(tags)))
+	for k, v := range tags {
+		b = appendString(b, k)
+		b = appendString(b, v)
+	}
+	return b
+}
+
+func appendInfo(b []byte, info osmpbf.Info) []byte {
+	var flags byte
+	if info.Visible {
+		flags |= flagVisible
+	}
+	if !info.Timestamp.IsZero() {
+		flags |= flagTimestamp
+	}
+	b = append(b, flags)
+	b = appendVarint(b, int64(info.Version))
+	if flags&flagTimestamp != 0 {
+		b = appendVarint(b, info.Timestamp.UnixNano()/int64(time.Millisecond))
+	}
+	b = appendVarint(b, info.Changeset)
+	b = appendVarint(b, int64(info.Uid))
+	return appendLiteral(b, info.User)
+}
+
+// appendString appends an interned string: its index in the table plus one,
+// or zero and the literal string.
+func appendString(b []byte, s string) []byte {
+	if i, ok := internIndex[s]; ok {
+		return appendUvarint(b, uint64(i+1))
+	}
+	return appendLiteral(appendUvarint(b, 0), s)
+}
+
+func appendLiteral(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// decoder reads a record, remembering the first error.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = ErrCorrupt
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = ErrCorrupt
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// count reads a number of items, each taking at least a byte.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.b)) {
+		d.err = ErrCorrupt
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.b) == 0 {
+		d.err = ErrCorrupt
+		return 0
+	}
+	c := d.b[0]
+	d.b = d.b[1:]
+	return c
+}
+
+func (d *decoder) string() string {
+	i := d.uvarint()
+	if i == 0 {
+		return d.literal()
+	}
+	if i > uint64(len(internTable)) {
+		d.err = ErrCorrupt
+		return ""
+	}
+	return internTable[i-1]
+}
+
+func (d *decoder) literal() string {
+	n := d.count()
+	if d.err != nil {
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+func (d *decoder) tags() map[string]string {
+	n := d.count()
+	tags := make(map[string]string, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		k := d.string()
+		tags[k] = d.string()
+	}
+	return tags
+}
+
+func (d *decoder) info() osmpbf.Info {
+	flags := d.byte()
+	info := osmpbf.Info{Visible: flags&flagVisible != 0}
+	info.Version = int32(d.varint())
+	if flags&flagTimestamp != 0 {
+		ms := d.varint()
+		info.Timestamp = time.Unix(ms/1000, ms%1000*int64(time.Millisecond)).UTC()
+	}
+	info.Changeset = d.varint()
+	info.Uid = int32(d.varint())
+	info.User = d.literal()
+	return info
+}
+
+// done returns the first error, or ErrCorrupt if bytes are left.
+func (d *decoder) done() error {
+	if d.err == nil && len(d.b) > 0 {
+		return ErrCorrupt
+	}
+	return d.err
+}
@@ -0,0 +1,180 @@
+package codec_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ambiweb/osm-pbf-filter/codec"
+	"github.com/qedus/osmpbf"
+)
+
+var info = osmpbf.Info{
+	Version:   12,
+	Timestamp: time.Unix(1767323045, 0).UTC(),
+	Changeset: 170000000,
+	Uid:       4242,
+	User:      "mapper",
+	Visible:   true,
+}
+
+var node = &osmpbf.Node{
+	ID:   -3,
+	Lat:  1e-9 * float64(100*525200066),
+	Lon:  1e-9 * float64(100*134049540),
+	Tags: map[string]string{"amenity": "cafe", "name": "Kaffee Mitte", "cuisine": "coffee_shop"},
+	Info: info,
+}
+
+var way = &osmpbf.Way{
+	ID:      4000000000,
+	Tags:    map[string]string{"highway": "residential", "name": "Hauptstraße"},
+	NodeIDs: []int64{11000000000, 11000000001, 10999999990, 11000000000},
+	Info:    info,
+}
+
+var relation = &osmpbf.Relation{
+	ID:   62422,
+	Tags: map[string]string{"type": "multipolygon", "landuse": "forest"},
+	Members: []osmpbf.Member{
+		{ID: 4000000000, Type: osmpbf.WayType, Role: "outer"},
+		{ID: 3999999999, Type: osmpbf.WayType, Role: "inner"},
+		{ID: 1, Type: osmpbf.NodeType, Role: "label"},
+		{ID: 62421, Type: osmpbf.RelationType, Role: "custom role"},
+	},
+	Info: osmpbf.Info{Visible: true},
+}
+
+func TestNodeRoundTrip(t *testing.T) {
+	actual, err := codec.DecodeNode(codec.AppendNode(nil, node))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(node, actual) {
+		t.Errorf("Expected %+v, actual %+v", node, actual)
+	}
+}
+
+func TestWayRoundTrip(t *testing.T) {
+	actual, err := codec.DecodeWay(codec.AppendWay(nil, way))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(way, actual) {
+		t.Errorf("Expected %+v, actual %+v", way, actual)
+	}
+}
+
+func TestRelationRoundTrip(t *testing.T) {
+	actual, err := codec.DecodeRelation(codec.AppendRelation(nil, relation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(relation, actual) {
+		t.Errorf("Expected %+v, actual %+v", relation, actual)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, v := range []interface{}{node, way, relation} {
+		b, err := codec.Append(nil, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := codec.Decode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, actual) {
+			t.Errorf("Expected %+v, actual %+v", v, actual)
+		}
+	}
+	if _, err := codec.Decode([]byte{9}); err != codec.ErrCorrupt {
+		t.Errorf("Expected %v, actual %v", codec.ErrCorrupt, err)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	b := codec.AppendWay(nil, way)
+	if _, err := codec.DecodeWay(b[:len(b)-1]); err != codec.ErrCorrupt {
+		t.Errorf("Expected %v, actual %v", codec.ErrCorrupt, err)
+	}
+	if _, err := codec.DecodeWay(append(b, 0)); err != codec.ErrCorrupt {
+		t.Errorf("Expected %v, actual %v", codec.ErrCorrupt, err)
+	}
+}
+
+func BenchmarkEncodeWay(b *testing.B) {
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf = codec.AppendWay(buf[:0], way)
+	}
+	b.SetBytes(int64(len(buf)))
+}
+
+func BenchmarkEncodeWayJSON(b *testing.B) {
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf, _ = json.Marshal(way)
+	}
+	b.SetBytes(int64(len(buf)))
+}
+
+func BenchmarkDecodeWay(b *testing.B) {
+	buf := codec.AppendWay(nil, way)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.DecodeWay(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeWayJSON(b *testing.B) {
+	buf, _ := json.Marshal(way)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		var w osmpbf.Way
+		if err := json.Unmarshal(buf, &w); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeNode(b *testing.B) {
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf = codec.AppendNode(buf[:0], node)
+	}
+	b.SetBytes(int64(len(buf)))
+}
+
+func BenchmarkEncodeNodeJSON(b *testing.B) {
+	var buf []byte
+	for i := 0; i < b.N; i++ {
+		buf, _ = json.Marshal(node)
+	}
+	b.SetBytes(int64(len(buf)))
+}
+
+func BenchmarkDecodeNode(b *testing.B) {
+	buf := codec.AppendNode(nil, node)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.DecodeNode(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeNodeJSON(b *testing.B) {
+	buf, _ := json.Marshal(node)
+	b.SetBytes(int64(len(buf)))
+	for i := 0; i < b.N; i++ {
+		var n osmpbf.Node
+		if err := json.Unmarshal(buf, &n); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package codec
+
+// internTable lists frequent tag keys, tag values and member roles, each
+// once. Records refer to them by index, so strings may be appended but never
+// reordered or removed without breaking stored records.
+var internTable = []string{
+	// values
+	"yes", "no", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10",
+	// keys
+	"access", "addr:city", "addr:country", "addr:housenumber", "addr:postcode",
+	"addr:street", "admin_level", "amenity", "area", "barrier", "boundary",
+	"bridge", "building", "building:levels", "created_by", "cuisine", "ele",
+	"highway", "landuse", "layer", "leisure", "maxspeed", "name", "natural",
+	"note", "oneway", "opening_hours", "place", "power", "railway", "ref",
+	"route", "service", "shop", "source", "surface", "tourism", "tunnel",
+	"type", "water", "waterway", "wikidata", "wikipedia",
+	// values of the keys above
+	"house", "residential", "detached", "garage", "apartments", "commercial",
+	"industrial", "retail", "roof", "shed", "unclassified", "tertiary",
+	"secondary", "primary", "trunk", "motorway", "track", "path", "footway",
+	"living_street", "steps", "cycleway", "pedestrian",
+	"driveway", "parking_aisle", "asphalt", "unpaved", "paved", "gravel",
+	"ground", "dirt", "grass", "concrete", "farmland", "forest", "meadow",
+	"wood", "scrub", "wetland", "tree", "parking", "bench",
+	"restaurant", "cafe", "bar", "pub", "fast_food", "school", "place_of_worship",
+	"fence", "wall", "gate", "stream", "ditch", "river", "drain", "city",
+	"town", "village", "hamlet", "locality", "administrative", "multipolygon",
+	"route_master", "bus", "tower", "pole", "line", "minor_line", "bing",
+	"survey",
+	// roles
+	"outer", "inner", "forward", "backward", "stop", "platform", "from",
+	"to", "via", "admin_centre", "label", "subarea", "main_stream", "side_stream",
+}
+
+var internIndex = make(map[string]int, len(internTable))
+
+func init() {
+	for i, s := range internTable {
+		if _, ok := internIndex[s]; ok {
+			panic("codec: " + s + " is interned twice")
+		}
+		internIndex[s] = i
+	}
+}
@@ -0,0 +1,77 @@
+// Package geo provides geographic regions to filter OSM entities with: a
+// bounding box and a polygon read from a GeoJSON or an Osmosis .poly file.
+package geo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Region tells if a position is inside of it.
+type Region interface {
+	Contains(lat, lon float64) bool
+}
+
+// BBox is a bounding box in degrees.
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// ParseBBox parses a bounding box given as "minlon,minlat,maxlon,maxlat".
+func ParseBBox(s string) (*BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox %q: expected minlon,minlat,maxlon,maxlat", s)
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bbox %q: %v", s, err)
+		}
+		v[i] = f
+	}
+	b := &BBox{v[0], v[1], v[2], v[3]}
+	if b.MinLon > b.MaxLon || b.MinLat > b.MaxLat {
+		return nil, fmt.Errorf("bbox %q: minimum is greater than maximum", s)
+	}
+	if b.MinLon < -180 || b.MaxLon > 180 || b.MinLat < -90 || b.MaxLat > 90 {
+		return nil, fmt.Errorf("bbox %q: out of range", s)
+	}
+	return b, nil
+}
+
+// Contains reports if the position is inside the box, borders included.
+func (b *BBox) Contains(lat, lon float64) bool {
+	return lon >= b.MinLon && lon <= b.MaxLon && lat >= b.MinLat && lat <= b.MaxLat
+}
+
+// extend grows the box to contain a position.
+func (b *BBox) extend(lat, lon float64) {
+	if lon < b.MinLon {
+		b.MinLon = lon
+	}
+	if lon > b.MaxLon {
+		b.MaxLon = lon
+	}
+	if lat < b.MinLat {
+		b.MinLat = lat
+	}
+	if lat > b.MaxLat {
+		b.MaxLat = lat
+	}
+}
+
+// All combines regions: a position is inside if it is inside all of them.
+type All []Region
+
+// Contains reports if the position is inside all regions.
+func (a All) Contains(lat, lon float64) bool {
+	for _, r := range a {
+		if !r.Contains(lat, lon) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package geo_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/geo"
+)
+
+var containsTests = []struct {
+	lat, lon float64
+	expected bool
+}{
+	{0.5, 0.5, true},
+	{2.5, 2.5, false}, // in the hole
+	{4.9, 0.1, true},
+	{5.5, 0.5, false},
+	{-0.5, 0.5, false},
+}
+
+func testRegion(t *testing.T, name string, r geo.Region) {
+	for _, tt := range containsTests {
+		if actual := r.Contains(tt.lat, tt.lon); actual != tt.expected {
+			t.Errorf("%s: %v,%v: expected %v, actual %v", name, tt.lat, tt.lon, tt.expected, actual)
+		}
+	}
+}
+
+func TestReadGeoJSON(t *testing.T) {
+	p, err := geo.ReadGeoJSON(strings.NewReader(`{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {}, "geometry": {"type": "Polygon", "coordinates": [
+			[[0, 0], [5, 0], [5, 5], [0, 5], [0, 0]],
+			[[2, 2], [3, 2], [3, 3], [2, 3], [2, 2]]
+		]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRegion(t, "geojson", p)
+}
+
+func TestReadPoly(t *testing.T) {
+	p, err := geo.ReadPoly(strings.NewReader(`square
+1
+   0.0E+00   0.0E+00
+   5.0E+00   0.0E+00
+   5.0E+00   5.0E+00
+   0.0E+00   5.0E+00
+END
+!2
+   2 2
+   3 2
+   3 3
+   2 3
+END
+END
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRegion(t, "poly", p)
+}
+
+var bboxTests = []struct {
+	s     string
+	valid bool
+}{
+	{"13.3,52.4,13.5,52.6", true},
+	{"13.3, 52.4, 13.5, 52.6", true},
+	{"13.3,52.4,13.5", false},
+	{"13.5,52.4,13.3,52.6", false},
+	{"13.3,52.4,13.5,95", false},
+	{"a,52.4,13.5,52.6", false},
+}
+
+func TestParseBBox(t *testing.T) {
+	for _, tt := range bboxTests {
+		b, err := geo.ParseBBox(tt.s)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, actual error %v", tt.s, tt.valid, err)
+			continue
+		}
+		if err == nil && !b.Contains(52.5, 13.4) {
+			t.Errorf("%s: expected to contain 52.5,13.4", tt.s)
+		}
+	}
+}
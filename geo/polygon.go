@@ -0,0 +1,234 @@
+package geo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ring is a closed line of positions as GeoJSON orders them: longitude, latitude.
+type ring [][2]float64
+
+// contains reports if the position is inside the ring, using the even-odd rule.
+func (r ring) contains(lat, lon float64) bool {
+	in := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a[1] > lat) != (b[1] > lat) &&
+			lon < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			in = !in
+		}
+	}
+	return in
+}
+
+// part is an outer ring with its holes.
+type part struct {
+	outer ring
+	holes []ring
+}
+
+// Polygon is an area made of one or more outer rings with holes.
+type Polygon struct {
+	parts []part
+	bbox  BBox
+}
+
+// NewPolygon returns a polygon of parts, each an outer ring followed by its
+// holes, as coordinates of a GeoJSON MultiPolygon.
+func NewPolygon(parts [][][][2]float64) (*Polygon, error) {
+	p := &Polygon{bbox: BBox{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}}
+	for _, rings := range parts {
+		if len(rings) == 0 {
+			continue
+		}
+		pt := part{outer: ring(rings[0])}
+		for _, r := range rings[1:] {
+			pt.holes = append(pt.holes, ring(r))
+		}
+		for _, c := range pt.outer {
+			p.bbox.extend(c[1], c[0])
+		}
+		if len(pt.outer) < 3 {
+			return nil, errors.New("polygon ring has less than 3 positions")
+		}
+		p.parts = append(p.parts, pt)
+	}
+	if len(p.parts) == 0 {
+		return nil, errors.New("polygon is empty")
+	}
+	return p, nil
+}
+
+// Contains reports if the position is inside an outer ring and not in its holes.
+func (p *Polygon) Contains(lat, lon float64) bool {
+	if !p.bbox.Contains(lat, lon) {
+		return false
+	}
+	for _, pt := range p.parts {
+		if !pt.outer.contains(lat, lon) {
+			continue
+		}
+		inHole := false
+		for _, h := range pt.holes {
+			if h.contains(lat, lon) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadPolygon reads a polygon from a file. Files with the .poly extension
+// are read as Osmosis polygon files, others as GeoJSON.
+func ReadPolygon(path string) (*Polygon, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var p *Polygon
+	if strings.EqualFold(filepath.Ext(path), ".poly") {
+		p, err = ReadPoly(f)
+	} else {
+		p, err = ReadGeoJSON(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+// geoJSON holds the members of GeoJSON objects used to find polygons.
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Geometries  []*geoJSON      `json:"geometries"`
+	Features    []*geoJSON      `json:"features"`
+}
+
+// ReadGeoJSON reads a polygon from a GeoJSON Polygon or MultiPolygon
+// geometry, or from a Feature, FeatureCollection or GeometryCollection of
+// them. All polygons found make up the result.
+func ReadGeoJSON(r io.Reader) (*Polygon, error) {
+	var g geoJSON
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return nil, err
+	}
+	var parts [][][][2]float64
+	if err := g.collect(&parts); err != nil {
+		return nil, err
+	}
+	return NewPolygon(parts)
+}
+
+func (g *geoJSON) collect(parts *[][][][2]float64) error {
+	switch g.Type {
+	case "Polygon":
+		var rings [][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+			return err
+		}
+		*parts = append(*parts, rings)
+	case "MultiPolygon":
+		var polygons [][][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return err
+		}
+		*parts = append(*parts, polygons...)
+	case "Feature":
+		if g.Geometry != nil {
+			return g.Geometry.collect(parts)
+		}
+	case "FeatureCollection", "GeometryCollection":
+		for _, c := range append(g.Features, g.Geometries...) {
+			if err := c.collect(parts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadPoly reads a polygon in the Osmosis polygon filter file format: a name
+// line followed by sections of "longitude latitude" lines, each section
+// starting with a name line and ending with END, and a final END. Sections
+// whose name starts with "!" are holes; a position in any hole is outside.
+func ReadPoly(r io.Reader) (*Polygon, error) {
+	s := bufio.NewScanner(r)
+	line := 0
+	next := func() (string, bool) {
+		for s.Scan() {
+			line++
+			if t := strings.TrimSpace(s.Text()); t != "" {
+				return t, true
+			}
+		}
+		return "", false
+	}
+	if _, ok := next(); !ok {
+		return nil, errors.New("poly: missing name")
+	}
+	var outers, holes []ring
+	for {
+		name, ok := next()
+		if !ok {
+			return nil, errors.New("poly: missing final END")
+		}
+		if name == "END" {
+			break
+		}
+		var rg ring
+		for {
+			t, ok := next()
+			if !ok {
+				return nil, fmt.Errorf("poly: section %s is not terminated with END", name)
+			}
+			if t == "END" {
+				break
+			}
+			fields := strings.Fields(t)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("poly: line %d: expected longitude and latitude", line)
+			}
+			lon, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("poly: line %d: %v", line, err)
+			}
+			lat, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("poly: line %d: %v", line, err)
+			}
+			rg = append(rg, [2]float64{lon, lat})
+		}
+		if strings.HasPrefix(name, "!") {
+			holes = append(holes, rg)
+		} else {
+			outers = append(outers, rg)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	parts := make([][][][2]float64, len(outers))
+	for i, o := range outers {
+		rings := [][][2]float64{o}
+		for _, h := range holes {
+			rings = append(rings, h)
+		}
+		parts[i] = rings
+	}
+	return NewPolygon(parts)
+}
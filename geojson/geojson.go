@@ -0,0 +1,95 @@
+// Package geojson writes OSM entities as a GeoJSON FeatureCollection.
+// Use NewWriter to start a collection, Write to add features and Close to
+// finish it. Use Rings and MultiPolygon to assemble area geometries from
+// way geometries.
+package geojson
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Point is a position as GeoJSON orders it: longitude, latitude.
+type Point [2]float64
+
+// Geometry is a GeoJSON geometry object.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// NewPoint returns a Point geometry.
+func NewPoint(p Point) *Geometry {
+	return &Geometry{Type: "Point", Coordinates: p}
+}
+
+// NewLineString returns a LineString geometry.
+func NewLineString(line []Point) *Geometry {
+	return &Geometry{Type: "LineString", Coordinates: line}
+}
+
+// NewPolygon returns a Polygon geometry. The first ring is the outer one.
+func NewPolygon(rings [][]Point) *Geometry {
+	return &Geometry{Type: "Polygon", Coordinates: rings}
+}
+
+// NewMultiPolygon returns a MultiPolygon geometry.
+func NewMultiPolygon(polygons [][][]Point) *Geometry {
+	return &Geometry{Type: "MultiPolygon", Coordinates: polygons}
+}
+
+// Feature is a GeoJSON feature object.
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// NewFeature returns a feature with properties holding tags.
+func NewFeature(id string, g *Geometry, tags map[string]string) *Feature {
+	properties := make(map[string]interface{}, len(tags))
+	for k, v := range tags {
+		properties[k] = v
+	}
+	return &Feature{Type: "Feature", ID: id, Geometry: g, Properties: properties}
+}
+
+// A Writer writes features of a FeatureCollection to an output stream.
+type Writer struct {
+	w     io.Writer
+	begun bool
+}
+
+// NewWriter returns a new writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes a feature.
+func (w *Writer) Write(f *Feature) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if !w.begun {
+		w.begun = true
+		sep = `{"type":"FeatureCollection","features":[` + "\n"
+	}
+	if _, err := io.WriteString(w.w, sep); err != nil {
+		return err
+	}
+	_, err = w.w.Write(b)
+	return err
+}
+
+// Close finishes the collection. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	end := "\n]}\n"
+	if !w.begun {
+		end = `{"type":"FeatureCollection","features":[]}` + "\n"
+	}
+	_, err := io.WriteString(w.w, end)
+	return err
+}
@@ -0,0 +1,142 @@
+package geojson
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrOpenRing is returned by Rings if lines can not be joined into closed rings.
+var ErrOpenRing = errors.New("geojson: lines do not form closed rings")
+
+// Line is a way geometry: IDs of its nodes and their positions.
+type Line struct {
+	IDs    []int64
+	Points []Point
+}
+
+// Closed reports if the line starts and ends at the same node.
+func (l Line) Closed() bool {
+	return len(l.IDs) > 3 && l.IDs[0] == l.IDs[len(l.IDs)-1]
+}
+
+// Rings joins lines sharing end nodes into closed rings, the way members of
+// a multipolygon relation form its outer and inner boundaries.
+func Rings(lines []Line) ([][]Point, error) {
+	used := make([]bool, len(lines))
+	var rings [][]Point
+	for i, l := range lines {
+		if used[i] || len(l.IDs) < 2 {
+			continue
+		}
+		used[i] = true
+		ids := append([]int64(nil), l.IDs...)
+		points := append([]Point(nil), l.Points...)
+		for ids[0] != ids[len(ids)-1] {
+			j, reverse := nextLine(lines, used, ids[len(ids)-1])
+			if j < 0 {
+				return nil, ErrOpenRing
+			}
+			used[j] = true
+			nids, npoints := lines[j].IDs, lines[j].Points
+			if reverse {
+				nids, npoints = reverseIDs(nids), reversePoints(npoints)
+			}
+			ids = append(ids, nids[1:]...)
+			points = append(points, npoints[1:]...)
+		}
+		if len(ids) < 4 {
+			return nil, ErrOpenRing
+		}
+		rings = append(rings, points)
+	}
+	return rings, nil
+}
+
+// nextLine finds an unused line starting or ending at node id.
+func nextLine(lines []Line, used []bool, id int64) (int, bool) {
+	for i, l := range lines {
+		if used[i] || len(l.IDs) < 2 {
+			continue
+		}
+		if l.IDs[0] == id {
+			return i, false
+		}
+		if l.IDs[len(l.IDs)-1] == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func reverseIDs(ids []int64) []int64 {
+	r := make([]int64, len(ids))
+	for i, id := range ids {
+		r[len(ids)-1-i] = id
+	}
+	return r
+}
+
+func reversePoints(points []Point) []Point {
+	r := make([]Point, len(points))
+	for i, p := range points {
+		r[len(points)-1-i] = p
+	}
+	return r
+}
+
+// MultiPolygon builds polygons from outer and inner rings. Every inner ring
+// becomes a hole of the smallest outer ring containing it; inner rings
+// outside of all outer rings are dropped. Rings are oriented as RFC 7946
+// requires: outer rings counterclockwise, holes clockwise.
+func MultiPolygon(outer, inner [][]Point) [][][]Point {
+	polygons := make([][][]Point, len(outer))
+	for i, ring := range outer {
+		polygons[i] = [][]Point{orient(ring, true)}
+	}
+	for _, ring := range inner {
+		best, bestArea := -1, math.Inf(1)
+		for i, o := range outer {
+			if a := math.Abs(area(o)); a < bestArea && contains(o, ring[0]) {
+				best, bestArea = i, a
+			}
+		}
+		if best >= 0 {
+			polygons[best] = append(polygons[best], orient(ring, false))
+		}
+	}
+	return polygons
+}
+
+// Polygon returns a polygon of a single closed ring oriented counterclockwise.
+func Polygon(ring []Point) [][]Point {
+	return [][]Point{orient(ring, true)}
+}
+
+// area returns the signed area of a ring, positive if counterclockwise.
+func area(ring []Point) float64 {
+	var a float64
+	for i := 0; i+1 < len(ring); i++ {
+		a += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return a / 2
+}
+
+func orient(ring []Point, ccw bool) []Point {
+	if (area(ring) > 0) != ccw {
+		return reversePoints(ring)
+	}
+	return ring
+}
+
+// contains reports if p is inside ring, using the even-odd rule.
+func contains(ring []Point, p Point) bool {
+	in := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > p[1]) != (b[1] > p[1]) &&
+			p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			in = !in
+		}
+	}
+	return in
+}
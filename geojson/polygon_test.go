@@ -0,0 +1,62 @@
+package geojson_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/geojson"
+)
+
+// square returns lines with node IDs from ids around a square of size s at x, y.
+func square(x, y, s float64, ids [5]int64) []geojson.Line {
+	p := []geojson.Point{{x, y}, {x + s, y}, {x + s, y + s}, {x, y + s}, {x, y}}
+	return []geojson.Line{
+		{IDs: ids[:3], Points: p[:3]},
+		// reversed, as member ways often are
+		{IDs: []int64{ids[4], ids[3], ids[2]}, Points: []geojson.Point{p[4], p[3], p[2]}},
+	}
+}
+
+func TestRings(t *testing.T) {
+	rings, err := geojson.Rings(square(0, 0, 1, [5]int64{1, 2, 3, 4, 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]geojson.Point{{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}}
+	if !reflect.DeepEqual(expected, rings) {
+		t.Errorf("Expected %v, actual %v", expected, rings)
+	}
+}
+
+func TestRingsOpen(t *testing.T) {
+	lines := square(0, 0, 1, [5]int64{1, 2, 3, 4, 1})[:1]
+	if _, err := geojson.Rings(lines); err != geojson.ErrOpenRing {
+		t.Errorf("Expected %v, actual %v", geojson.ErrOpenRing, err)
+	}
+}
+
+func TestMultiPolygon(t *testing.T) {
+	outer, err := geojson.Rings(append(
+		square(0, 0, 10, [5]int64{1, 2, 3, 4, 1}),
+		square(20, 0, 10, [5]int64{5, 6, 7, 8, 5})...,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := geojson.Rings(square(21, 1, 1, [5]int64{9, 10, 11, 12, 9}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	polygons := geojson.MultiPolygon(outer, inner)
+	if len(polygons) != 2 {
+		t.Fatalf("Expected 2 polygons, actual %d", len(polygons))
+	}
+	if len(polygons[0]) != 1 || len(polygons[1]) != 2 {
+		t.Errorf("Expected the hole in the second polygon, actual %v", polygons)
+	}
+	// holes are clockwise
+	hole := polygons[1][1]
+	if hole[1] != (geojson.Point{21, 2}) {
+		t.Errorf("Expected clockwise hole, actual %v", hole)
+	}
+}
@@ -0,0 +1,101 @@
+package osmxml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Action is what an OsmChange file does with the entities of an element.
+type Action string
+
+// Actions of OsmChange files.
+const (
+	Create Action = "create"
+	Modify Action = "modify"
+	Delete Action = "delete"
+)
+
+func isAction(s string) bool {
+	return s == string(Create) || s == string(Modify) || s == string(Delete)
+}
+
+// A ChangeEncoder writes OsmChange data to an output stream.
+type ChangeEncoder struct {
+	w      *bufio.Writer
+	enc    *xml.Encoder
+	action Action
+	root   bool
+}
+
+// NewChangeEncoder returns a new encoder that writes to w.
+func NewChangeEncoder(w io.Writer) *ChangeEncoder {
+	bw := bufio.NewWriter(w)
+	enc := xml.NewEncoder(bw)
+	enc.Indent("", "  ")
+	return &ChangeEncoder{w: bw, enc: enc}
+}
+
+var changeName = xml.Name{Local: "osmChange"}
+
+// Encode writes a pointer to Node, Way or Relation struct within an element
+// of the action. Consecutive entities with the same action share it.
+func (enc *ChangeEncoder) Encode(action Action, v interface{}) error {
+	if !isAction(string(action)) {
+		return fmt.Errorf("osmxml: unknown action %q", action)
+	}
+	if err := enc.writeRoot(); err != nil {
+		return err
+	}
+	if action != enc.action {
+		if err := enc.endAction(); err != nil {
+			return err
+		}
+		if err := enc.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: string(action)}}); err != nil {
+			return err
+		}
+		enc.action = action
+	}
+	return encodeEntity(enc.enc, v)
+}
+
+// Close writes the end of the osmChange element and flushes the output.
+func (enc *ChangeEncoder) Close() error {
+	if err := enc.writeRoot(); err != nil {
+		return err
+	}
+	if err := enc.endAction(); err != nil {
+		return err
+	}
+	if err := enc.enc.EncodeToken(xml.EndElement{Name: changeName}); err != nil {
+		return err
+	}
+	if err := enc.enc.Flush(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(enc.w, "\n"); err != nil {
+		return err
+	}
+	return enc.w.Flush()
+}
+
+func (enc *ChangeEncoder) writeRoot() error {
+	if enc.root {
+		return nil
+	}
+	enc.root = true
+	if _, err := io.WriteString(enc.w, xml.Header); err != nil {
+		return err
+	}
+	return enc.enc.EncodeToken(rootElement(changeName))
+}
+
+func (enc *ChangeEncoder) endAction() error {
+	if enc.action == "" {
+		return nil
+	}
+	name := string(enc.action)
+	enc.action = ""
+	return enc.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+}
@@ -0,0 +1,137 @@
+package osmxml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/qedus/osmpbf"
+)
+
+// A Decoder reads and decodes OpenStreetMap XML data from an input stream.
+// It reads OsmChange data too.
+type Decoder struct {
+	dec    *xml.Decoder
+	action Action
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: xml.NewDecoder(r)}
+}
+
+// Decode reads the next node, way or relation element and returns a pointer
+// to a Node, Way or Relation struct. Other elements, like bounds, are
+// skipped. It returns io.EOF at the end of the input.
+func (dec *Decoder) Decode() (interface{}, error) {
+	for {
+		t, err := dec.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if ee, ok := t.(xml.EndElement); ok && isAction(ee.Name.Local) {
+			dec.action = ""
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case string(Create), string(Modify), string(Delete):
+			dec.action = Action(se.Name.Local)
+		case "node":
+			var n node
+			if err := dec.dec.DecodeElement(&n, &se); err != nil {
+				return nil, err
+			}
+			info, err := n.info()
+			if err != nil {
+				return nil, err
+			}
+			return &osmpbf.Node{
+				ID:   n.ID,
+				Lat:  degrees(n.Lat),
+				Lon:  degrees(n.Lon),
+				Tags: n.tags(),
+				Info: info,
+			}, nil
+		case "way":
+			var w way
+			if err := dec.dec.DecodeElement(&w, &se); err != nil {
+				return nil, err
+			}
+			info, err := w.info()
+			if err != nil {
+				return nil, err
+			}
+			v := &osmpbf.Way{ID: w.ID, Tags: w.tags(), Info: info}
+			v.NodeIDs = make([]int64, len(w.Nds))
+			for i, nd := range w.Nds {
+				v.NodeIDs[i] = nd.Ref
+			}
+			return v, nil
+		case "relation":
+			var r relation
+			if err := dec.dec.DecodeElement(&r, &se); err != nil {
+				return nil, err
+			}
+			info, err := r.info()
+			if err != nil {
+				return nil, err
+			}
+			v := &osmpbf.Relation{ID: r.ID, Tags: r.tags(), Info: info}
+			v.Members = make([]osmpbf.Member, len(r.Members))
+			for i, m := range r.Members {
+				t, err := memberType(m.Type)
+				if err != nil {
+					return nil, fmt.Errorf("osmxml: relation %d: %v", r.ID, err)
+				}
+				v.Members[i] = osmpbf.Member{ID: m.Ref, Type: t, Role: m.Role}
+			}
+			return v, nil
+		}
+	}
+}
+
+// DecodeChange decodes the next entity like Decode and returns the action of
+// the OsmChange element it is in, empty for entities outside of them.
+func (dec *Decoder) DecodeChange() (Action, interface{}, error) {
+	v, err := dec.Decode()
+	return dec.action, v, err
+}
+
+func (e *entity) tags() map[string]string {
+	tags := make(map[string]string, len(e.Tags))
+	for _, t := range e.Tags {
+		tags[t.K] = t.V
+	}
+	return tags
+}
+
+func (e *entity) info() (osmpbf.Info, error) {
+	info := osmpbf.Info{
+		Version:   e.Version,
+		Changeset: e.Changeset,
+		Uid:       e.UID,
+		User:      e.User,
+		Visible:   e.Visible != "false",
+	}
+	if e.Timestamp != "" {
+		t, err := time.Parse(time.RFC3339, e.Timestamp)
+		if err != nil {
+			return info, fmt.Errorf("osmxml: %d: %v", e.ID, err)
+		}
+		info.Timestamp = t.UTC()
+	}
+	return info, nil
+}
+
+func memberType(s string) (osmpbf.MemberType, error) {
+	for t, name := range memberTypes {
+		if name == s {
+			return osmpbf.MemberType(t), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown member type %q", s)
+}
@@ -0,0 +1,154 @@
+package osmxml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/qedus/osmpbf"
+)
+
+// An Encoder writes OpenStreetMap XML data to an output stream.
+type Encoder struct {
+	w             *bufio.Writer
+	enc           *xml.Encoder
+	bounds        *osmpbf.BoundingBox
+	headerWritten bool
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	bw := bufio.NewWriter(w)
+	enc := xml.NewEncoder(bw)
+	enc.Indent("", "  ")
+	return &Encoder{w: bw, enc: enc}
+}
+
+// SetHeader takes the bounding box of h, e.g. the header of a PBF input, to
+// write as bounds element. Call it before Encode.
+func (enc *Encoder) SetHeader(h *osmpbf.Header) {
+	enc.bounds = nil
+	if h != nil {
+		enc.bounds = h.BoundingBox
+	}
+}
+
+// Encode writes a pointer to Node, Way or Relation struct as a node, way or
+// relation element.
+func (enc *Encoder) Encode(v interface{}) error {
+	if err := enc.writeHeader(); err != nil {
+		return err
+	}
+	return encodeEntity(enc.enc, v)
+}
+
+// encodeEntity encodes a Node, Way or Relation as an element.
+func encodeEntity(enc *xml.Encoder, v interface{}) error {
+	var (
+		name string
+		e    interface{}
+	)
+	switch v := v.(type) {
+	case *osmpbf.Node:
+		name = "node"
+		e = &node{
+			entity: newEntity(v.ID, v.Tags, v.Info),
+			Lat:    round(v.Lat),
+			Lon:    round(v.Lon),
+		}
+	case *osmpbf.Way:
+		name = "way"
+		w := &way{entity: newEntity(v.ID, v.Tags, v.Info), Nds: make([]nd, len(v.NodeIDs))}
+		for i, id := range v.NodeIDs {
+			w.Nds[i].Ref = id
+		}
+		e = w
+	case *osmpbf.Relation:
+		name = "relation"
+		r := &relation{entity: newEntity(v.ID, v.Tags, v.Info), Members: make([]member, len(v.Members))}
+		for i, m := range v.Members {
+			r.Members[i] = member{Type: memberTypes[m.Type], Ref: m.ID, Role: m.Role}
+		}
+		e = r
+	default:
+		return fmt.Errorf("osmxml: unknown type %T", v)
+	}
+	return enc.EncodeElement(e, xml.StartElement{Name: xml.Name{Local: name}})
+}
+
+// Close writes the end of the osm element and flushes the output.
+func (enc *Encoder) Close() error {
+	if err := enc.writeHeader(); err != nil {
+		return err
+	}
+	if err := enc.enc.EncodeToken(xml.EndElement{Name: osmName}); err != nil {
+		return err
+	}
+	if err := enc.enc.Flush(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(enc.w, "\n"); err != nil {
+		return err
+	}
+	return enc.w.Flush()
+}
+
+func (enc *Encoder) writeHeader() error {
+	if enc.headerWritten {
+		return nil
+	}
+	enc.headerWritten = true
+	if _, err := io.WriteString(enc.w, xml.Header); err != nil {
+		return err
+	}
+	if err := enc.enc.EncodeToken(rootElement(osmName)); err != nil {
+		return err
+	}
+	if b := enc.bounds; b != nil {
+		e := bounds{MinLat: round(b.Bottom), MinLon: round(b.Left), MaxLat: round(b.Top), MaxLon: round(b.Right)}
+		return enc.enc.EncodeElement(e, xml.StartElement{Name: xml.Name{Local: "bounds"}})
+	}
+	return nil
+}
+
+var osmName = xml.Name{Local: "osm"}
+
+func rootElement(name xml.Name) xml.StartElement {
+	return xml.StartElement{Name: name, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "version"}, Value: "0.6"},
+		{Name: xml.Name{Local: "generator"}, Value: generator},
+	}}
+}
+
+func newEntity(id int64, tags map[string]string, info osmpbf.Info) entity {
+	e := entity{
+		ID:        id,
+		Version:   info.Version,
+		Timestamp: timestamp(info.Timestamp),
+		UID:       info.Uid,
+		User:      info.User,
+		Changeset: info.Changeset,
+	}
+	// entities without metadata are not flagged as deleted
+	if !info.Visible && info.Version != 0 {
+		e.Visible = "false"
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	e.Tags = make([]tag, len(keys))
+	for i, k := range keys {
+		e.Tags[i] = tag{K: k, V: tags[k]}
+	}
+	return e
+}
+
+// round rounds degrees to the 7 decimals of OSM data.
+func round(deg float64) float64 {
+	return math.Floor(deg*1e7+0.5) / 1e7
+}
@@ -0,0 +1,87 @@
+// Package osmxml encodes and decodes OpenStreetMap (OSM) XML and OsmChange
+// files, as JOSM and the OSM API use them. Entities are the Node, Way and Relation structs
+// of the github.com/qedus/osmpbf decoder.
+//
+// Use this package by creating a NewEncoder and passing it a writer, or a
+// NewDecoder and passing it a reader. The decoder streams: it keeps one
+// entity in memory at a time.
+package osmxml
+
+import (
+	"math"
+	"time"
+
+	"github.com/qedus/osmpbf"
+)
+
+const generator = "osm-pbf-filter"
+
+// entity holds the attributes and tags all entity elements have.
+type entity struct {
+	ID        int64  `xml:"id,attr"`
+	Visible   string `xml:"visible,attr,omitempty"`
+	Version   int32  `xml:"version,attr,omitempty"`
+	Timestamp string `xml:"timestamp,attr,omitempty"`
+	UID       int32  `xml:"uid,attr,omitempty"`
+	User      string `xml:"user,attr,omitempty"`
+	Changeset int64  `xml:"changeset,attr,omitempty"`
+	Tags      []tag  `xml:"tag"`
+}
+
+type tag struct {
+	K string `xml:"k,attr"`
+	V string `xml:"v,attr"`
+}
+
+type bounds struct {
+	MinLat float64 `xml:"minlat,attr"`
+	MinLon float64 `xml:"minlon,attr"`
+	MaxLat float64 `xml:"maxlat,attr"`
+	MaxLon float64 `xml:"maxlon,attr"`
+}
+
+type node struct {
+	entity
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+type way struct {
+	entity
+	Nds []nd `xml:"nd"`
+}
+
+type nd struct {
+	Ref int64 `xml:"ref,attr"`
+}
+
+type relation struct {
+	entity
+	Members []member `xml:"member"`
+}
+
+type member struct {
+	Type string `xml:"type,attr"`
+	Ref  int64  `xml:"ref,attr"`
+	Role string `xml:"role,attr"`
+}
+
+// memberTypes are names of member types in XML.
+var memberTypes = [...]string{
+	osmpbf.NodeType:     "node",
+	osmpbf.WayType:      "way",
+	osmpbf.RelationType: "relation",
+}
+
+// degrees rounds to the 7 decimals of OSM data and returns the value the
+// osmpbf decoder would, so entities read from XML and PBF compare equal.
+func degrees(deg float64) float64 {
+	return 1e-9 * float64(100*int64(math.Floor(deg*1e7+0.5)))
+}
+
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,132 @@
+package osmxml_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ambiweb/osm-pbf-filter/osmxml"
+	"github.com/qedus/osmpbf"
+)
+
+var info = osmpbf.Info{
+	Version:   3,
+	Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	Changeset: 42,
+	Uid:       7,
+	User:      "mapper & co",
+	Visible:   true,
+}
+
+var entities = []interface{}{
+	&osmpbf.Node{ID: 1, Lat: 1e-9 * float64(100*525200066), Lon: 1e-9 * float64(100*134049540), Tags: map[string]string{}, Info: info},
+	&osmpbf.Node{ID: -2, Lat: 1e-9 * float64(100*-338688197), Lon: 1e-9 * float64(100*1512092955), Tags: map[string]string{"amenity": "cafe", "name": `"Central" <1>`}, Info: info},
+	&osmpbf.Way{ID: 10, Tags: map[string]string{"highway": "primary"}, NodeIDs: []int64{-2, 1, -2}, Info: info},
+	&osmpbf.Relation{ID: 100, Tags: map[string]string{"type": "route"}, Members: []osmpbf.Member{
+		{ID: 10, Type: osmpbf.WayType, Role: "forward"},
+		{ID: 1, Type: osmpbf.NodeType, Role: "stop"},
+	}, Info: info},
+}
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	enc := osmxml.NewEncoder(&buf)
+	for _, v := range entities {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dec := osmxml.NewDecoder(&buf)
+	for _, expected := range entities {
+		actual, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %+v, actual %+v", expected, actual)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Expected %v, actual %v", io.EOF, err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	enc := osmxml.NewEncoder(&buf)
+	if err := enc.Encode(entities[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="osm-pbf-filter">
+  <way id="10" version="3" timestamp="2026-01-02T03:04:05Z" uid="7" user="mapper &amp; co" changeset="42">
+    <tag k="highway" v="primary"></tag>
+    <nd ref="-2"></nd>
+    <nd ref="1"></nd>
+    <nd ref="-2"></nd>
+  </way>
+</osm>
+`
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Expected %s, actual %s", expected, actual)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	dec := osmxml.NewDecoder(strings.NewReader(`<?xml version='1.0' encoding='UTF-8'?>
+<osm version="0.6" generator="JOSM">
+  <bounds minlat="52.5" minlon="13.4" maxlat="52.6" maxlon="13.5"/>
+  <node id="5" visible="false" version="2" lat="52.51" lon="13.41"/>
+</osm>`))
+	v, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &osmpbf.Node{ID: 5, Lat: 1e-9 * float64(100*525100000), Lon: 1e-9 * float64(100*134100000),
+		Tags: map[string]string{}, Info: osmpbf.Info{Version: 2}}
+	if !reflect.DeepEqual(expected, v) {
+		t.Errorf("Expected %+v, actual %+v", expected, v)
+	}
+}
+
+func TestChangeRoundTrip(t *testing.T) {
+	actions := []osmxml.Action{osmxml.Create, osmxml.Create, osmxml.Modify, osmxml.Delete}
+	var buf bytes.Buffer
+	enc := osmxml.NewChangeEncoder(&buf)
+	for i, v := range entities {
+		if err := enc.Encode(actions[i], v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "<create>"); n != 1 {
+		t.Errorf("Expected 1 create element, actual %d in %s", n, buf.String())
+	}
+	dec := osmxml.NewDecoder(&buf)
+	for i, expected := range entities {
+		action, actual, err := dec.DecodeChange()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action != actions[i] {
+			t.Errorf("Expected %v, actual %v", actions[i], action)
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Expected %+v, actual %+v", expected, actual)
+		}
+	}
+	if _, _, err := dec.DecodeChange(); err != io.EOF {
+		t.Errorf("Expected %v, actual %v", io.EOF, err)
+	}
+}
@@ -0,0 +1,356 @@
+// Package pbf encodes OpenStreetMap (OSM) PBF files.
+// It is the counterpart of the github.com/qedus/osmpbf decoder.
+// Use this package by creating a NewEncoder and passing it a writer.
+// Use Encode to write Node, Way and Relation structs sorted by type and ID.
+// Use Close to flush the last block.
+package pbf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/qedus/osmpbf"
+	"github.com/qedus/osmpbf/OSMPBF"
+)
+
+const (
+	// blockSize is the number of entities in a PrimitiveBlock, the same
+	// osmium and osmosis use.
+	blockSize = 8000
+
+	granularity     = 100
+	dateGranularity = 1000
+
+	writingProgram = "osm-pbf-filter"
+)
+
+// ErrUnsorted is returned by Encode if entities are not sorted by type and ID.
+var ErrUnsorted = errors.New("pbf: entities are not sorted by type and ID")
+
+// An Encoder writes OpenStreetMap PBF data to an output stream.
+type Encoder struct {
+	w             io.Writer
+	header        *osmpbf.Header
+	headerWritten bool
+
+	// entities of the pending block, all of the same type
+	typ      osmpbf.MemberType
+	lastID   int64
+	started  bool
+	entities []interface{}
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetHeader sets the bounding box, source and replication state to write to
+// the OSMHeader block, e.g. those of the input. Features and the writing
+// program are those of the encoder. Call it before Encode.
+func (enc *Encoder) SetHeader(h *osmpbf.Header) {
+	enc.header = h
+}
+
+// Encode writes a pointer to Node, Way or Relation struct. Entities must be
+// passed nodes first, then ways, then relations, each sorted by ID, so the
+// output can be flagged as Sort.Type_then_ID.
+func (enc *Encoder) Encode(v interface{}) error {
+	t, id, err := typeID(v)
+	if err != nil {
+		return err
+	}
+	if enc.started && (t < enc.typ || t == enc.typ && id <= enc.lastID) {
+		return ErrUnsorted
+	}
+	if enc.started && t != enc.typ || len(enc.entities) == blockSize {
+		if err := enc.flush(); err != nil {
+			return err
+		}
+	}
+	enc.started = true
+	enc.typ, enc.lastID = t, id
+	enc.entities = append(enc.entities, v)
+	return nil
+}
+
+// Close writes pending entities. It does not close the underlying writer.
+func (enc *Encoder) Close() error {
+	return enc.flush()
+}
+
+func typeID(v interface{}) (osmpbf.MemberType, int64, error) {
+	switch v := v.(type) {
+	case *osmpbf.Node:
+		return osmpbf.NodeType, v.ID, nil
+	case *osmpbf.Way:
+		return osmpbf.WayType, v.ID, nil
+	case *osmpbf.Relation:
+		return osmpbf.RelationType, v.ID, nil
+	}
+	return 0, 0, fmt.Errorf("pbf: unknown type %T", v)
+}
+
+func (enc *Encoder) flush() error {
+	if !enc.headerWritten {
+		if err := enc.writeHeader(); err != nil {
+			return err
+		}
+	}
+	if len(enc.entities) == 0 {
+		return nil
+	}
+	block := newBlockEncoder().encode(enc.typ, enc.entities)
+	enc.entities = enc.entities[:0]
+	return enc.writeBlock("OSMData", block)
+}
+
+func (enc *Encoder) writeHeader() error {
+	enc.headerWritten = true
+	header := &OSMPBF.HeaderBlock{
+		RequiredFeatures: []string{"OsmSchema-V0.6", "DenseNodes"},
+		OptionalFeatures: []string{"Sort.Type_then_ID"},
+		Writingprogram:   proto.String(writingProgram),
+	}
+	if h := enc.header; h != nil {
+		if b := h.BoundingBox; b != nil {
+			header.Bbox = &OSMPBF.HeaderBBox{
+				Left:   proto.Int64(nanodegrees(b.Left)),
+				Right:  proto.Int64(nanodegrees(b.Right)),
+				Top:    proto.Int64(nanodegrees(b.Top)),
+				Bottom: proto.Int64(nanodegrees(b.Bottom)),
+			}
+		}
+		if h.Source != "" {
+			header.Source = proto.String(h.Source)
+		}
+		if !h.OsmosisReplicationTimestamp.IsZero() {
+			header.OsmosisReplicationTimestamp = proto.Int64(h.OsmosisReplicationTimestamp.Unix())
+		}
+		if h.OsmosisReplicationSequenceNumber != 0 {
+			header.OsmosisReplicationSequenceNumber = proto.Int64(h.OsmosisReplicationSequenceNumber)
+		}
+		if h.OsmosisReplicationBaseUrl != "" {
+			header.OsmosisReplicationBaseUrl = proto.String(h.OsmosisReplicationBaseUrl)
+		}
+	}
+	return enc.writeBlock("OSMHeader", header)
+}
+
+// writeBlock writes a fileblock: the size of the BlobHeader, the BlobHeader
+// and the zlib compressed Blob.
+func (enc *Encoder) writeBlock(typ string, m proto.Message) error {
+	raw, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	blob, err := proto.Marshal(&OSMPBF.Blob{
+		RawSize:  proto.Int32(int32(len(raw))),
+		ZlibData: buf.Bytes(),
+	})
+	if err != nil {
+		return err
+	}
+	blobHeader, err := proto.Marshal(&OSMPBF.BlobHeader{
+		Type:     proto.String(typ),
+		Datasize: proto.Int32(int32(len(blob))),
+	})
+	if err != nil {
+		return err
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(blobHeader)))
+	for _, b := range [][]byte{size[:], blobHeader, blob} {
+		if _, err := enc.w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockEncoder builds a PrimitiveBlock and its string table.
+type blockEncoder struct {
+	strings []string
+	index   map[string]int
+}
+
+func newBlockEncoder() *blockEncoder {
+	// the first entry of a string table is always blank and unused
+	return &blockEncoder{
+		strings: []string{""},
+		index:   map[string]int{"": 0},
+	}
+}
+
+func (be *blockEncoder) sid(s string) int {
+	i, ok := be.index[s]
+	if !ok {
+		i = len(be.strings)
+		be.index[s] = i
+		be.strings = append(be.strings, s)
+	}
+	return i
+}
+
+func (be *blockEncoder) encode(t osmpbf.MemberType, entities []interface{}) *OSMPBF.PrimitiveBlock {
+	group := &OSMPBF.PrimitiveGroup{}
+	switch t {
+	case osmpbf.NodeType:
+		group.Dense = be.denseNodes(entities)
+	case osmpbf.WayType:
+		for _, v := range entities {
+			group.Ways = append(group.Ways, be.way(v.(*osmpbf.Way)))
+		}
+	case osmpbf.RelationType:
+		for _, v := range entities {
+			group.Relations = append(group.Relations, be.relation(v.(*osmpbf.Relation)))
+		}
+	}
+	return &OSMPBF.PrimitiveBlock{
+		Stringtable:     &OSMPBF.StringTable{S: be.strings},
+		Primitivegroup:  []*OSMPBF.PrimitiveGroup{group},
+		Granularity:     proto.Int32(granularity),
+		DateGranularity: proto.Int32(dateGranularity),
+	}
+}
+
+func (be *blockEncoder) denseNodes(entities []interface{}) *OSMPBF.DenseNodes {
+	dn := &OSMPBF.DenseNodes{}
+	di := &OSMPBF.DenseInfo{}
+	var id, lat, lon, timestamp, changeset int64
+	var uid, userSid int32
+	tagged, withInfo := false, false
+	for _, v := range entities {
+		n := v.(*osmpbf.Node)
+		if len(n.Tags) > 0 {
+			tagged = true
+		}
+		if n.Info.Version != 0 {
+			withInfo = true
+		}
+	}
+	for _, v := range entities {
+		n := v.(*osmpbf.Node)
+		nlat, nlon := coordinate(n.Lat), coordinate(n.Lon)
+		dn.Id = append(dn.Id, n.ID-id)
+		dn.Lat = append(dn.Lat, nlat-lat)
+		dn.Lon = append(dn.Lon, nlon-lon)
+		id, lat, lon = n.ID, nlat, nlon
+
+		if tagged {
+			for _, k := range sortedKeys(n.Tags) {
+				dn.KeysVals = append(dn.KeysVals, int32(be.sid(k)), int32(be.sid(n.Tags[k])))
+			}
+			dn.KeysVals = append(dn.KeysVals, 0)
+		}
+
+		if withInfo {
+			ts, sid := be.timestamp(n.Info), int32(be.sid(n.Info.User))
+			di.Version = append(di.Version, n.Info.Version)
+			di.Timestamp = append(di.Timestamp, ts-timestamp)
+			di.Changeset = append(di.Changeset, n.Info.Changeset-changeset)
+			di.Uid = append(di.Uid, n.Info.Uid-uid)
+			di.UserSid = append(di.UserSid, sid-userSid)
+			timestamp, changeset, uid, userSid = ts, n.Info.Changeset, n.Info.Uid, sid
+		}
+	}
+	if withInfo {
+		dn.Denseinfo = di
+	}
+	return dn
+}
+
+func (be *blockEncoder) way(w *osmpbf.Way) *OSMPBF.Way {
+	pw := &OSMPBF.Way{Id: proto.Int64(w.ID), Info: be.info(w.Info)}
+	pw.Keys, pw.Vals = be.tags(w.Tags)
+	var ref int64
+	for _, id := range w.NodeIDs {
+		pw.Refs = append(pw.Refs, id-ref) // delta encoding
+		ref = id
+	}
+	return pw
+}
+
+func (be *blockEncoder) relation(r *osmpbf.Relation) *OSMPBF.Relation {
+	pr := &OSMPBF.Relation{Id: proto.Int64(r.ID), Info: be.info(r.Info)}
+	pr.Keys, pr.Vals = be.tags(r.Tags)
+	var memID int64
+	for _, m := range r.Members {
+		pr.Memids = append(pr.Memids, m.ID-memID) // delta encoding
+		memID = m.ID
+		pr.RolesSid = append(pr.RolesSid, int32(be.sid(m.Role)))
+		var t OSMPBF.Relation_MemberType
+		switch m.Type {
+		case osmpbf.NodeType:
+			t = OSMPBF.Relation_NODE
+		case osmpbf.WayType:
+			t = OSMPBF.Relation_WAY
+		case osmpbf.RelationType:
+			t = OSMPBF.Relation_RELATION
+		}
+		pr.Types = append(pr.Types, t)
+	}
+	return pr
+}
+
+func (be *blockEncoder) tags(tags map[string]string) (keys, vals []uint32) {
+	for _, k := range sortedKeys(tags) {
+		keys = append(keys, uint32(be.sid(k)))
+		vals = append(vals, uint32(be.sid(tags[k])))
+	}
+	return keys, vals
+}
+
+func (be *blockEncoder) info(info osmpbf.Info) *OSMPBF.Info {
+	if info.Version == 0 {
+		return nil
+	}
+	return &OSMPBF.Info{
+		Version:   proto.Int32(info.Version),
+		Timestamp: proto.Int64(be.timestamp(info)),
+		Changeset: proto.Int64(info.Changeset),
+		Uid:       proto.Int32(info.Uid),
+		UserSid:   proto.Uint32(uint32(be.sid(info.User))),
+	}
+}
+
+func (be *blockEncoder) timestamp(info osmpbf.Info) int64 {
+	if info.Timestamp.IsZero() {
+		return 0
+	}
+	return info.Timestamp.Unix() * 1000 / dateGranularity
+}
+
+// coordinate converts degrees to granularity units.
+func coordinate(deg float64) int64 {
+	return int64(math.Floor(deg*1e9/granularity + 0.5))
+}
+
+// nanodegrees converts degrees to the units of the header bounding box.
+func nanodegrees(deg float64) int64 {
+	return int64(math.Floor(deg*1e9 + 0.5))
+}
+
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,159 @@
+package pbf_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ambiweb/osm-pbf-filter/pbf"
+	"github.com/qedus/osmpbf"
+)
+
+var info = osmpbf.Info{
+	Version:   3,
+	Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	Changeset: 42,
+	Uid:       7,
+	User:      "mapper",
+	Visible:   true,
+}
+
+var entities = []interface{}{
+	&osmpbf.Node{ID: 1, Lat: 52.5200066, Lon: 13.404954, Tags: map[string]string{}, Info: info},
+	&osmpbf.Node{ID: 2, Lat: -33.8688197, Lon: 151.2092955, Tags: map[string]string{"amenity": "cafe", "name": "Central"}, Info: info},
+	&osmpbf.Way{ID: 10, Tags: map[string]string{"highway": "primary"}, NodeIDs: []int64{2, 1, 2}, Info: info},
+	&osmpbf.Relation{ID: 100, Tags: map[string]string{"type": "route"}, Members: []osmpbf.Member{
+		{ID: 10, Type: osmpbf.WayType, Role: "forward"},
+		{ID: 1, Type: osmpbf.NodeType, Role: "stop"},
+	}, Info: info},
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	enc := pbf.NewEncoder(&buf)
+	for _, v := range entities {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec := osmpbf.NewDecoder(&buf)
+	if err := dec.Start(1); err != nil {
+		t.Fatal(err)
+	}
+	var actual []interface{}
+	for {
+		v, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual = append(actual, v)
+	}
+
+	if len(actual) != len(entities) {
+		t.Fatalf("Expected %d entities, actual %d", len(entities), len(actual))
+	}
+	for i, expected := range entities {
+		if n, ok := actual[i].(*osmpbf.Node); ok {
+			e := expected.(*osmpbf.Node)
+			if d := n.Lat - e.Lat; d > 1e-7 || d < -1e-7 {
+				t.Errorf("Expected lat %v, actual %v", e.Lat, n.Lat)
+			}
+			if d := n.Lon - e.Lon; d > 1e-7 || d < -1e-7 {
+				t.Errorf("Expected lon %v, actual %v", e.Lon, n.Lon)
+			}
+			n.Lat, n.Lon = e.Lat, e.Lon
+		}
+		if !reflect.DeepEqual(expected, actual[i]) {
+			t.Errorf("Expected %+v, actual %+v", expected, actual[i])
+		}
+	}
+}
+
+func TestEncodeUnsorted(t *testing.T) {
+	enc := pbf.NewEncoder(&bytes.Buffer{})
+	if err := enc.Encode(entities[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Encode(entities[0]); err != pbf.ErrUnsorted {
+		t.Errorf("Expected %v, actual %v", pbf.ErrUnsorted, err)
+	}
+}
+
+var header = &osmpbf.Header{
+	BoundingBox: &osmpbf.BoundingBox{
+		Left:   1e-9 * float64(13300000000),
+		Right:  1e-9 * float64(13500000000),
+		Top:    1e-9 * float64(52600000000),
+		Bottom: 1e-9 * float64(52400000000),
+	},
+	OptionalFeatures:                 []string{"LocationsOnWays"},
+	WritingProgram:                   "osmium/1.16.0",
+	Source:                           "extract",
+	OsmosisReplicationTimestamp:      time.Unix(1767323045, 0).UTC(),
+	OsmosisReplicationSequenceNumber: 4711,
+	OsmosisReplicationBaseUrl:        "https://planet.openstreetmap.org/replication/day",
+}
+
+func TestReadInfo(t *testing.T) {
+	var buf bytes.Buffer
+	enc := pbf.NewEncoder(&buf)
+	enc.SetHeader(header)
+	for _, v := range entities {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := pbf.ReadInfo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &pbf.Info{
+		Header: osmpbf.Header{
+			BoundingBox:                      header.BoundingBox,
+			RequiredFeatures:                 []string{"OsmSchema-V0.6", "DenseNodes"},
+			OptionalFeatures:                 []string{"Sort.Type_then_ID"},
+			WritingProgram:                   "osm-pbf-filter",
+			Source:                           header.Source,
+			OsmosisReplicationTimestamp:      header.OsmosisReplicationTimestamp,
+			OsmosisReplicationSequenceNumber: header.OsmosisReplicationSequenceNumber,
+			OsmosisReplicationBaseUrl:        header.OsmosisReplicationBaseUrl,
+		},
+		HeaderBlocks: 1,
+		DataBlocks:   3,
+	}
+	if !reflect.DeepEqual(expected, info) {
+		t.Errorf("Expected %+v, actual %+v", expected, info)
+	}
+}
+
+func TestReadInfoTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	enc := pbf.NewEncoder(&buf)
+	for _, v := range entities {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()[:buf.Len()-10]
+	// the data block is skipped with Seek, or read from a reader without it
+	for _, r := range []io.Reader{bytes.NewReader(data), bytes.NewBuffer(data)} {
+		if _, err := pbf.ReadInfo(r); err != io.ErrUnexpectedEOF {
+			t.Errorf("%T: expected %v, actual %v", r, io.ErrUnexpectedEOF, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package pbf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/qedus/osmpbf"
+	"github.com/qedus/osmpbf/OSMPBF"
+)
+
+// maxBlobHeaderSize is the limit osmpbf.Decoder puts on blob headers.
+const maxBlobHeaderSize = 64 * 1024
+
+// Info describes a PBF file: the contents of its OSMHeader block and the
+// number of its blocks.
+type Info struct {
+	osmpbf.Header
+	HeaderBlocks int
+	DataBlocks   int
+}
+
+// ReadInfo reads the blocks of a PBF file. Only OSMHeader blocks are
+// decoded; data blocks are counted and skipped, with Seek if r supports it.
+func ReadInfo(r io.Reader) (*Info, error) {
+	info := &Info{}
+	for {
+		header, err := ReadBlobHeader(r)
+		if err == io.EOF {
+			return info, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		size := int64(header.GetDatasize())
+		if header.GetType() != "OSMHeader" {
+			info.DataBlocks++
+			if err := skip(r, size); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info.HeaderBlocks++
+		data, err := readBlob(r, size)
+		if err != nil {
+			return nil, err
+		}
+		var hb OSMPBF.HeaderBlock
+		if err := proto.Unmarshal(data, &hb); err != nil {
+			return nil, err
+		}
+		info.Header = *osmpbf.NewHeader(&hb)
+	}
+}
+
+// ReadBlobHeader reads the size of a BlobHeader and the BlobHeader, which
+// tells the type and size of the Blob following it.
+func ReadBlobHeader(r io.Reader) (*OSMPBF.BlobHeader, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n >= maxBlobHeaderSize {
+		return nil, errors.New("pbf: blob header size >= 64Kb")
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	header := new(OSMPBF.BlobHeader)
+	if err := proto.Unmarshal(buf, header); err != nil {
+		return nil, err
+	}
+	if header.GetDatasize() >= osmpbf.MaxBlobSize {
+		return nil, errors.New("pbf: blob size >= 32Mb")
+	}
+	return header, nil
+}
+
+// readBlob reads a Blob and returns its uncompressed data.
+func readBlob(r io.Reader, size int64) ([]byte, error) {
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	var blob OSMPBF.Blob
+	if err := proto.Unmarshal(buf, &blob); err != nil {
+		return nil, err
+	}
+	switch {
+	case blob.Raw != nil:
+		return blob.Raw, nil
+	case blob.ZlibData != nil:
+		zr, err := zlib.NewReader(bytes.NewReader(blob.ZlibData))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		data := make([]byte, blob.GetRawSize())
+		if _, err := io.ReadFull(zr, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("pbf: unsupported blob compression")
+}
+
+// skip skips n bytes, reading them if r can not seek, e.g. a pipe. Seeking
+// past the end is not an error, so the size is checked first.
+func skip(r io.Reader, n int64) error {
+	if s, ok := r.(io.Seeker); ok {
+		if offset, err := s.Seek(0, io.SeekCurrent); err == nil {
+			size, err := s.Seek(0, io.SeekEnd)
+			if err != nil {
+				return err
+			}
+			if offset+n > size {
+				return io.ErrUnexpectedEOF
+			}
+			_, err = s.Seek(offset+n, io.SeekStart)
+			return err
+		}
+	}
+	_, err := io.CopyN(ioutil.Discard, r, n)
+	return unexpectedEOF(err)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package run
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Keys of the state of levelDB, which let a later run on the same input
+// reuse it or resume an interrupted PutData.
+var (
+	phaseKey    = []byte("meta:phase")
+	progressKey = []byte("meta:progress")
+	headerKey   = []byte("meta:header")
+	// updatedKey marks levelDB holding changes applied by Update, which
+	// the input files do not have
+	updatedKey = []byte("meta:updated")
+)
+
+// phasePut is recorded when PutData has stored all of the input.
+const phasePut = "put"
+
+// checkpointInterval is the number of items PutData reads between records
+// of its progress.
+const checkpointInterval = 100000
+
+// storedState returns the phase completed by earlier runs and the number of
+// items an interrupted PutData has read.
+func (c *Command) storedState() (phase string, progress int64, err error) {
+	b, err := c.dbGet(phaseKey)
+	if err == nil {
+		return string(b), 0, nil
+	}
+	if err != leveldb.ErrNotFound {
+		return "", 0, err
+	}
+	b, err = c.dbGet(progressKey)
+	if err == leveldb.ErrNotFound {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	progress, err = strconv.ParseInt(string(b), 10, 64)
+	return "", progress, err
+}
+
+// checkNotUpdated returns an error if Update has applied changes to levelDB,
+// so that Run does not take them for the input.
+func (c *Command) checkNotUpdated() error {
+	_, err := c.dbGet(updatedKey)
+	switch err {
+	case nil:
+		return errors.New("the levelDB cache holds changes applied by update: use update to filter it, or delete it to filter the input files alone")
+	case leveldb.ErrNotFound:
+		return nil
+	}
+	return err
+}
+
+// rematch drops collected, inside and matched markers left by earlier runs, possibly
+// with other filters, and matches stored items again. Nodes are matched
+// before ways and relations, so region matching sees members before their
+// parents.
+func (c *Command) rematch() error {
+	if err := c.uncollect(); err != nil {
+		return err
+	}
+	if err := c.deletePrefix(insideKeyPrefix); err != nil {
+		return err
+	}
+	if err := c.deletePrefix(matchedKeyPrefix); err != nil {
+		return err
+	}
+	if err := c.rematchType(osmpbf.NodeType); err != nil {
+		return err
+	}
+	return c.matchInside()
+}
+
+// uncollect moves collected items back to their plain keys.
+func (c *Command) uncollect() error {
+	iter := c.LevelDB.NewIterator(util.BytesPrefix(collectedKeyPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := c.dbPut(iter.Key()[len(collectedKeyPrefix):], iter.Value()); err != nil {
+			return err
+		}
+		if err := c.dbDelete(iter.Key()); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+func (c *Command) deletePrefix(prefix []byte) error {
+	iter := c.LevelDB.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		if err := c.dbDelete(iter.Key()); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// logResume tells what is reused of an earlier run.
+func logResume(phase string, progress int64) {
+	switch {
+	case phase == phasePut:
+		log.Print("Reusing data stored in levelDB by an earlier run")
+	case progress > 0:
+		log.Printf("Resuming to transfer data from PBF to levelDB after %d items", progress)
+	}
+}
@@ -0,0 +1,37 @@
+package run_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/ambiweb/osm-pbf-filter/tags"
+)
+
+func TestReuse(t *testing.T) {
+	c := newCommand(t)
+	defer c.LevelDB.Close()
+	for _, expr := range []string{"type=route", "amenity", "building", "amenity"} {
+		x, err := tags.ParseExpr(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := sliceDecoder(input)
+		c.PBFDecoder = &d
+		c.TagsMatcher = x
+		c.Closure = run.ClosureSmart
+		c.Format = run.FormatJSON
+		var b bytes.Buffer
+		c.Stdout = &b
+		if err := run.Run(c); err != nil {
+			t.Fatal(err)
+		}
+		expected, err := selectInput(t, run.StrategyDB, run.ClosureSmart, run.MissingSkip, expr, "", run.FormatJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := b.String(); expected != actual {
+			t.Errorf("%s: expected %s, actual %s", expr, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package run
+
+import (
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Closure strategies tell CollectRelated which related items to collect so
+// that the output is referentially complete. They are named after osmium
+// extract strategies.
+const (
+	// ClosureSimple collects nodes of collected ways and members of
+	// collected relations, without nodes of member ways.
+	ClosureSimple = "simple"
+	// ClosureCompleteWays also collects nodes of member ways.
+	ClosureCompleteWays = "complete_ways"
+	// ClosureSmart also collects multipolygon and boundary relations the
+	// collected ways are members of, along with all their members, so every
+	// multipolygon touched by the output is complete.
+	ClosureSmart = "smart"
+)
+
+func (c *Command) collectNodes(ids []int64) error {
+	for _, id := range ids {
+		_, _, err := c.collectKey(osmpbf.NodeType, id)
+		if err == leveldb.ErrNotFound {
+			err = c.missingMember(osmpbf.NodeType, id)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectMultipolygons collects multipolygon and boundary relations with
+// collected member ways.
+func (c *Command) collectMultipolygons() error {
+	iter := c.LevelDB.NewIterator(util.BytesPrefix(typeKeyPrefix(osmpbf.RelationType)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		e, err := decodeEntity(iter.Key(), iter.Value())
+		if err != nil {
+			return err
+		}
+		v := e.v.(*osmpbf.Relation)
+		if t := v.Tags["type"]; t != "multipolygon" && t != "boundary" {
+			continue
+		}
+		touched, err := c.anyWayCollected(v.Members)
+		if err != nil {
+			return err
+		}
+		if !touched {
+			continue
+		}
+		_, collected, err := c.collectKey(osmpbf.RelationType, v.ID)
+		if err != nil {
+			return err
+		}
+		if collected {
+			continue
+		}
+		if err := c.collectMembers(v.Members); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+func (c *Command) anyWayCollected(members []osmpbf.Member) (bool, error) {
+	for _, m := range members {
+		if m.Type != osmpbf.WayType {
+			continue
+		}
+		key := (&DBKey{Type: m.Type, ID: m.ID}).Bytes()
+		ok, err := c.LevelDB.Has(prefixed(collectedKeyPrefix, key), nil)
+		if ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
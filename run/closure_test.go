@@ -0,0 +1,141 @@
+package run_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/ambiweb/osm-pbf-filter/tags"
+	"github.com/qedus/osmpbf"
+)
+
+// sliceDecoder decodes entities from a slice.
+type sliceDecoder []interface{}
+
+func (d *sliceDecoder) Decode() (interface{}, error) {
+	if len(*d) == 0 {
+		return nil, io.EOF
+	}
+	v := (*d)[0]
+	*d = (*d)[1:]
+	return v, nil
+}
+
+var input = []interface{}{
+	&osmpbf.Node{ID: 1, Tags: map[string]string{}},
+	&osmpbf.Node{ID: 2, Tags: map[string]string{}},
+	&osmpbf.Node{ID: 3, Tags: map[string]string{"amenity": "cafe"}},
+	&osmpbf.Node{ID: 4, Tags: map[string]string{}},
+	&osmpbf.Way{ID: 10, Tags: map[string]string{"building": "yes"}, NodeIDs: []int64{1, 2, 3, 1}},
+	&osmpbf.Way{ID: 11, Tags: map[string]string{}, NodeIDs: []int64{3, 4}},
+	&osmpbf.Relation{ID: 100, Tags: map[string]string{"type": "multipolygon"}, Members: []osmpbf.Member{
+		{ID: 10, Type: osmpbf.WayType, Role: "outer"},
+	}},
+	&osmpbf.Relation{ID: 101, Tags: map[string]string{"type": "route"}, Members: []osmpbf.Member{
+		{ID: 11, Type: osmpbf.WayType},
+		{ID: 102, Type: osmpbf.RelationType},
+	}},
+	&osmpbf.Relation{ID: 102, Tags: map[string]string{"type": "route_master"}, Members: []osmpbf.Member{
+		{ID: 101, Type: osmpbf.RelationType},
+		{ID: 999, Type: osmpbf.WayType},
+	}},
+	// a site with a relation read before it and one missing in the input
+	&osmpbf.Relation{ID: 103, Tags: map[string]string{"type": "site"}, Members: []osmpbf.Member{
+		{ID: 100, Type: osmpbf.RelationType},
+		{ID: 998, Type: osmpbf.RelationType},
+	}},
+}
+
+// inputCommand returns a command on input selecting items with expr and ids,
+// either of which may be empty.
+func inputCommand(t *testing.T, strategy, closure, missing, expr, ids, format string) *run.Command {
+	c := newCommand(t)
+	open := func() (run.Decoder, error) {
+		d := sliceDecoder(input)
+		return &d, nil
+	}
+	c.PBFDecoder, _ = open()
+	c.Reopen = open
+	if expr != "" {
+		x, err := tags.ParseExpr(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.TagsMatcher = x
+	}
+	if ids != "" {
+		c.IDs = &run.IDs{}
+		if err := c.IDs.Parse(ids); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Closure = closure
+	c.Missing = missing
+	c.Strategy = strategy
+	c.Format = format
+	return c
+}
+
+// selectInput runs inputCommand and returns its output.
+func selectInput(t *testing.T, strategy, closure, missing, expr, ids, format string) (string, error) {
+	c := inputCommand(t, strategy, closure, missing, expr, ids, format)
+	defer c.LevelDB.Close()
+	var b bytes.Buffer
+	c.Stdout = &b
+	err := run.Run(c)
+	return b.String(), err
+}
+
+// refs returns the references of the items of JSON output, e.g.
+// "n1 w10 r100", telling their type by their fields.
+func refs(t *testing.T, output string) string {
+	var items []map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &items); err != nil {
+		t.Fatal(err)
+	}
+	var refs []string
+	for _, item := range items {
+		typ := 'n'
+		if _, ok := item["NodeIDs"]; ok {
+			typ = 'w'
+		} else if _, ok := item["Members"]; ok {
+			typ = 'r'
+		}
+		refs = append(refs, fmt.Sprintf("%c%v", typ, item["ID"]))
+	}
+	return strings.Join(refs, " ")
+}
+
+var closureTests = []struct {
+	closure  string
+	expr     string
+	expected string
+}{
+	{run.ClosureSimple, "building", "n1 n2 n3 w10"},
+	{run.ClosureSimple, "type=multipolygon", "w10 r100"},
+	{run.ClosureSimple, "type=route", "w11 r101 r102"},
+	{run.ClosureCompleteWays, "building", "n1 n2 n3 w10"},
+	{run.ClosureCompleteWays, "type=multipolygon", "n1 n2 n3 w10 r100"},
+	{run.ClosureCompleteWays, "type=route", "n3 n4 w11 r101 r102"},
+	{run.ClosureSmart, "building", "n1 n2 n3 w10 r100"},
+	{run.ClosureSmart, "amenity", "n3"},
+	{run.ClosureSmart, "type=route", "n3 n4 w11 r101 r102"},
+}
+
+func TestClosure(t *testing.T) {
+	for _, strategy := range []string{run.StrategyDB, run.StrategyTwoPass} {
+		for _, tt := range closureTests {
+			output, err := selectInput(t, strategy, tt.closure, run.MissingSkip, tt.expr, "", run.FormatJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := refs(t, output); actual != tt.expected {
+				t.Errorf("%s %s %s: expected %s, actual %s", strategy, tt.closure, tt.expr, tt.expected, actual)
+			}
+		}
+	}
+}
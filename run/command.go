@@ -1,10 +1,11 @@
 package run
 
 import (
-	"encoding/json"
+	"fmt"
 	"io"
-	"log"
+	"strconv"
 
+	"github.com/ambiweb/osm-pbf-filter/geo"
 	"github.com/ambiweb/osm-pbf-filter/tags"
 	"github.com/qedus/osmpbf"
 	"github.com/syndtr/goleveldb/leveldb"
@@ -13,44 +14,179 @@ import (
 
 var collectedKeyPrefix = []byte("collected")
 
+// matchedKeyPrefix marks items matching the filters themselves, as opposed
+// to items collected as related ones.
+var matchedKeyPrefix = []byte("matched")
+
+// Output formats.
+const (
+	FormatJSON    = "json"
+	FormatNDJSON  = "ndjson"
+	FormatCSV     = "csv"
+	FormatPBF     = "pbf"
+	FormatOSM     = "osm"
+	FormatGeoJSON = "geojson"
+)
+
 // Command represents an environment and settings for a command to run.
 type Command struct {
-	PBFDecoder  *osmpbf.Decoder
+	PBFDecoder  Decoder  // decodes the input, PBF or another format
+	Reopen      OpenFunc // opens the input again for strategies reading it more than once
 	LevelDB     *leveldb.DB
-	TagsMatcher tags.Matcher
+	Dedupe      bool // keep the newest version of items read more than once
+	TagsMatcher tags.Expr
+	IDs         *IDs // items to select by ID besides those TagsMatcher does
+	Region      geo.Region
+	Closure     string
+	Missing     string
+	Strategy    string
+	Format      string
+	CSVColumns  []string      // tags written as columns by FormatCSV
+	Writer      Writer        // writes the output instead of a writer of Format
+	Changes     ChangeDecoder // changes to apply by Update
+	Diff        bool          // output changes of the extract by Update
 	Stdout      io.Writer
+	Progress    *Progress // reports phases and the summary of the run
+
+	missing missingSummary
 }
 
 // Run executes main logic.
 func Run(c *Command) error {
-	log.Print("Start transfering data from PBF to levelDB")
-	if err := c.PutData(); err != nil {
+	switch c.Strategy {
+	case StrategyDB, "":
+		if err := c.checkNotUpdated(); err != nil {
+			return err
+		}
+		if err := c.store(); err != nil {
+			return err
+		}
+	case StrategyTwoPass:
+		if err := c.runTwoPass(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown strategy %q", c.Strategy)
+	}
+	c.missing.log()
+	c.Progress.phase("output", fmt.Sprintf("Preparing to output %s", c.Format))
+	if err := c.Output(); err != nil {
 		return err
 	}
-	log.Print("Start collecting related items")
-	if err := c.CollectRelated(); err != nil {
+	return c.summary()
+}
+
+// store stores the input in levelDB, unless an earlier run has, and collects
+// matching and related items.
+func (c *Command) store() error {
+	phase, progress, err := c.storedState()
+	if err != nil {
 		return err
 	}
-	log.Print("Preparing to output JSON")
-	if err := c.OutputJSON(); err != nil {
+	logResume(phase, progress)
+	if phase != phasePut {
+		c.Progress.phase("put", "Start transfering data from PBF to levelDB")
+		if err := c.PutData(); err != nil {
+			return err
+		}
+	}
+	switch {
+	case phase == phasePut || progress > 0:
+		c.Progress.phase("rematch", "Start matching items stored by an earlier run")
+		if err := c.rematch(); err != nil {
+			return err
+		}
+	case c.Region != nil:
+		c.Progress.phase("region", "Start matching ways and relations inside the region")
+		if err := c.matchInside(); err != nil {
+			return err
+		}
+	}
+	c.Progress.phase("collect", "Start collecting related items")
+	return c.CollectRelated()
+}
+
+// Output outputs collected entries with the writer of the command or, if
+// there is none, in its format.
+func (c *Command) Output() error {
+	w := c.Writer
+	if w == nil {
+		var err error
+		if w, err = c.NewWriter(c.Format); err != nil {
+			return err
+		}
+	}
+	return c.output(w)
+}
+
+// output writes collected entries with w.
+func (c *Command) output(w Writer) error {
+	err := c.TraverseCollectedRaw(func(k, v []byte) error {
+		e, err := decodeEntity(k[len(collectedKeyPrefix):], v)
+		if err != nil {
+			return err
+		}
+		return w.Write(e.v)
+	})
+	if err != nil {
 		return err
 	}
-	return nil
+	return w.Close()
 }
 
 // PutData reads data from PBF and saves it in levelDB.
-// If data item matches tags, it is saved as collected.
+// If data item matches tags and is inside the region, it is saved as collected.
+// With a region, only nodes are matched; matchInside matches the others.
+// It records its progress, so that it skips items stored by an interrupted
+// run, and its completion.
 func (c *Command) PutData() error {
-	return c.TraverseData(func(v interface{}) error {
+	_, skip, err := c.storedState()
+	if err != nil {
+		return err
+	}
+	var n int64
+	err = c.TraverseData(func(v interface{}) error {
+		n++
+		c.Progress.readItem(v)
+		if n <= skip {
+			return nil
+		}
+		if c.Dedupe {
+			stale, err := c.stale(v)
+			if stale || err != nil {
+				return err
+			}
+		}
 		fn := c.Put
-		if c.TagsMatch(v) {
-			fn = c.Collect
+		// ways and relations are matched against the region by
+		// matchInside, once their members are stored
+		if _, ok := v.(*osmpbf.Node); ok || c.Region == nil {
+			inside, err := c.regionMatch(v)
+			if err != nil {
+				return err
+			}
+			if inside && c.TagsMatch(v) {
+				fn = c.collectMatched
+			}
 		}
 		if err := fn(v); err != nil {
 			return err
 		}
+		if n%checkpointInterval == 0 {
+			return c.dbPut(progressKey, strconv.AppendInt(nil, n, 10))
+		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	if err := c.putHeader(); err != nil {
+		return err
+	}
+	if err := c.dbPut(phaseKey, []byte(phasePut)); err != nil {
+		return err
+	}
+	return c.dbDelete(progressKey)
 }
 
 // TraverseDataFunc is a function to use with TraverseData Command method.
@@ -58,8 +194,23 @@ type TraverseDataFunc func(interface{}) error
 
 // TraverseData loops through the data and executes function on every data item.
 func (c *Command) TraverseData(fn TraverseDataFunc) error {
+	return traverse(c.decodePBF, c.counted(fn))
+}
+
+// counted counts the items fn is called with as progress.
+func (c *Command) counted(fn TraverseDataFunc) TraverseDataFunc {
+	if c.Progress == nil {
+		return fn
+	}
+	return func(v interface{}) error {
+		c.Progress.item()
+		return fn(v)
+	}
+}
+
+func traverse(decode func() (interface{}, error), fn TraverseDataFunc) error {
 	for {
-		v, err := c.decodePBF()
+		v, err := decode()
 
 		if err == io.EOF {
 			return nil
@@ -84,7 +235,8 @@ func (c *Command) Put(v interface{}) error {
 	return c.dbPut(key, value)
 }
 
-// TagsMatch checks if Tags match the tags matching rules.
+// TagsMatch checks if Tags match the tags matching rules, or if the item is
+// selected by ID.
 func (c *Command) TagsMatch(v interface{}) bool {
 	return c.tagsMatch(v)
 }
@@ -95,25 +247,48 @@ func (c *Command) Collect(v interface{}) error {
 	if err != nil {
 		return err
 	}
-	key = append(collectedKeyPrefix, key...)
-	return c.dbPut(key, value)
+	return c.dbPut(prefixed(collectedKeyPrefix, key), value)
 }
 
-// CollectRelated marks related values of previously collected items as collected.
+// collectMatched stores a matching item as collected and marks it as matched.
+func (c *Command) collectMatched(v interface{}) error {
+	if err := c.Collect(v); err != nil {
+		return err
+	}
+	key, _, err := KeyValue(v)
+	if err != nil {
+		return err
+	}
+	return c.dbPut(prefixed(matchedKeyPrefix, key), nil)
+}
+
+// CollectRelated marks related values of previously collected items as
+// collected. What is related depends on the closure strategy of the command.
 func (c *Command) CollectRelated() error {
-	return c.TraverseCollected(func(k []byte, v interface{}) error {
-		switch v := v.(type) {
+	err := c.TraverseCollectedRaw(func(k, v []byte) error {
+		e, err := decodeEntity(k[len(collectedKeyPrefix):], v)
+		if err != nil {
+			return err
+		}
+		switch v := e.v.(type) {
+		case *osmpbf.Way:
+			return c.collectNodes(v.NodeIDs)
 		case *osmpbf.Relation:
-			log.Print("Relation found. Collecting members")
-			if err := c.collectMembers(v.Members); err != nil {
-				return err
-			}
+			return c.collectMembers(v.Members)
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	if c.Closure == ClosureSmart {
+		return c.collectMultipolygons()
+	}
+	return nil
 }
 
 // TraverseCollectedFunc is a function to use with TraverseCollected Command method.
+// v is a *osmpbf.Node, *osmpbf.Way or *osmpbf.Relation.
 type TraverseCollectedFunc func(k []byte, v interface{}) error
 
 // TraverseCollected loops through the collected items and executes function on every item.
@@ -121,67 +296,87 @@ func (c *Command) TraverseCollected(fn TraverseCollectedFunc) error {
 	iter := c.LevelDB.NewIterator(util.BytesPrefix(collectedKeyPrefix), nil)
 	defer iter.Release()
 	for iter.Next() {
-		log.Print("Got collected item. Decoding...")
-		var value interface{}
-		if err := json.Unmarshal(iter.Value(), &value); err != nil {
+		e, err := decodeEntity(iter.Key()[len(collectedKeyPrefix):], iter.Value())
+		if err != nil {
 			return err
 		}
-		if err := fn(iter.Key(), value); err != nil {
+		if err := fn(iter.Key(), e.v); err != nil {
 			return err
 		}
 	}
 	return iter.Error()
 }
 
+// collectMembers collects members and, recursively, members of member
+// relations. Traversal is iterative and visits every member once, so relation
+// cycles and deep super-relation hierarchies are safe.
 func (c *Command) collectMembers(members []osmpbf.Member) error {
-	for _, m := range members {
-		dbKey := &DBKey{Type: m.Type, ID: m.ID}
-		key, err := dbKey.Bytes()
-		if err != nil {
-			return err
+	stack := append([]osmpbf.Member(nil), members...)
+	visited := make(map[DBKey]bool)
+	for len(stack) > 0 {
+		m := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		k := DBKey{Type: m.Type, ID: m.ID}
+		if visited[k] {
+			continue
 		}
-		value, err := c.dbGet(key)
-		if err != nil {
-			return err
+		visited[k] = true
+
+		value, collected, err := c.collectKey(m.Type, m.ID)
+		if err == leveldb.ErrNotFound {
+			if err := c.missingMember(m.Type, m.ID); err != nil {
+				return err
+			}
+			continue
 		}
-		if err := c.dbDelete(key); err != nil {
+		if err != nil {
 			return err
 		}
-		key = append(collectedKeyPrefix, key...)
-		if err := c.dbPut(key, value); err != nil {
-			return err
+		if collected {
+			// members of items collected before are collected already
+			continue
 		}
 
-		if m.Type == osmpbf.RelationType {
-			var v osmpbf.Relation
-			if err := json.Unmarshal(value, &v); err != nil {
+		switch m.Type {
+		case osmpbf.WayType:
+			if c.Closure == ClosureSimple {
+				continue
+			}
+			e, err := decodeEntity(k.Bytes(), value)
+			if err != nil {
 				return err
 			}
-			if err := c.collectMembers(v.Members); err != nil {
+			if err := c.collectNodes(e.v.(*osmpbf.Way).NodeIDs); err != nil {
 				return err
 			}
+		case osmpbf.RelationType:
+			e, err := decodeEntity(k.Bytes(), value)
+			if err != nil {
+				return err
+			}
+			stack = append(stack, e.v.(*osmpbf.Relation).Members...)
 		}
 	}
 	return nil
 }
 
-// OutputJSON outputs collected entries as JSON.
-func (c *Command) OutputJSON() error {
-	io.WriteString(c.Stdout, "[")
-	comma := ""
-	err := c.TraverseCollectedRaw(func(_, v []byte) error {
-		io.WriteString(c.Stdout, comma)
-		c.Stdout.Write(v)
-		if comma == "" {
-			comma = ","
-		}
-		return nil
-	})
+// collectKey marks a stored item as collected and returns its value. It
+// reports whether the item had been collected before.
+func (c *Command) collectKey(t osmpbf.MemberType, id int64) (value []byte, collected bool, err error) {
+	key := (&DBKey{Type: t, ID: id}).Bytes()
+	collectedKey := prefixed(collectedKeyPrefix, key)
+	value, err = c.dbGet(key)
+	if err == leveldb.ErrNotFound {
+		value, err = c.dbGet(collectedKey)
+		return value, err == nil, err
+	}
 	if err != nil {
-		return err
+		return nil, false, err
 	}
-	io.WriteString(c.Stdout, "]")
-	return nil
+	if err := c.dbDelete(key); err != nil {
+		return nil, false, err
+	}
+	return value, false, c.dbPut(collectedKey, value)
 }
 
 // TraverseCollectedRawFunc is a function to use with TraverseCollectedRaw Command method.
@@ -192,6 +387,7 @@ func (c *Command) TraverseCollectedRaw(fn TraverseCollectedRawFunc) error {
 	iter := c.LevelDB.NewIterator(util.BytesPrefix(collectedKeyPrefix), nil)
 	defer iter.Release()
 	for iter.Next() {
+		c.Progress.item()
 		if err := fn(iter.Key(), iter.Value()); err != nil {
 			return err
 		}
@@ -0,0 +1,10 @@
+package run
+
+// Copy writes every entity of the input with w, e.g. to convert between
+// formats, and closes w.
+func Copy(w Writer, dec Decoder) error {
+	if err := traverse(dec.Decode, w.Write); err != nil {
+		return err
+	}
+	return w.Close()
+}
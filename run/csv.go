@@ -0,0 +1,62 @@
+package run
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/qedus/osmpbf"
+)
+
+// csvWriter writes nodes as CSV rows of ID, position and tag columns.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	row     []string
+	header  bool
+}
+
+// NewCSVWriter returns a writer of nodes as CSV with a header row and the
+// columns id, lat, lon and the values of the tags in columns, empty if a
+// node does not have one. Ways and relations, which have no position, are
+// left out.
+func NewCSVWriter(w io.Writer, columns []string) Writer {
+	return &csvWriter{
+		w:       csv.NewWriter(w),
+		columns: columns,
+		row:     make([]string, 3+len(columns)),
+	}
+}
+
+func (cw *csvWriter) Write(v interface{}) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	n, ok := v.(*osmpbf.Node)
+	if !ok {
+		return nil
+	}
+	cw.row[0] = strconv.FormatInt(n.ID, 10)
+	cw.row[1] = strconv.FormatFloat(n.Lat, 'f', 7, 64)
+	cw.row[2] = strconv.FormatFloat(n.Lon, 'f', 7, 64)
+	for i, k := range cw.columns {
+		cw.row[3+i] = n.Tags[k]
+	}
+	return cw.w.Write(cw.row)
+}
+
+func (cw *csvWriter) writeHeader() error {
+	if cw.header {
+		return nil
+	}
+	cw.header = true
+	return cw.w.Write(append([]string{"id", "lat", "lon"}, cw.columns...))
+}
+
+func (cw *csvWriter) Close() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
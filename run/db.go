@@ -1,21 +1,88 @@
 package run
 
 import (
-	"encoding/json"
+	"encoding/binary"
+	"errors"
 	"fmt"
 
+	"github.com/ambiweb/osm-pbf-filter/codec"
 	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 // DBKey represents a key for a levelDB record.
 type DBKey struct {
-	Type osmpbf.MemberType `json:"t"`
-	ID   int64             `json:"i"`
+	Type osmpbf.MemberType
+	ID   int64
 }
 
-// Bytes returns byte slice representation of a key.
-func (key *DBKey) Bytes() ([]byte, error) {
-	return json.Marshal(key)
+// dbKeySize is the size of a key: a type byte and an 8 byte ID.
+const dbKeySize = 9
+
+// Bytes returns byte slice representation of a key. Keys are the type byte
+// followed by the ID in big-endian order with the sign bit flipped, so they
+// sort by type and then by ID, negative IDs first.
+func (key *DBKey) Bytes() []byte {
+	b := make([]byte, dbKeySize)
+	b[0] = byte(key.Type)
+	binary.BigEndian.PutUint64(b[1:], uint64(key.ID)^1<<63)
+	return b
+}
+
+// ParseDBKey parses a key returned by DBKey.Bytes.
+func ParseDBKey(b []byte) (DBKey, error) {
+	if len(b) != dbKeySize || b[0] > byte(osmpbf.RelationType) {
+		return DBKey{}, fmt.Errorf("invalid key %x", b)
+	}
+	return DBKey{
+		Type: osmpbf.MemberType(b[0]),
+		ID:   int64(binary.BigEndian.Uint64(b[1:]) ^ 1<<63),
+	}, nil
+}
+
+// typeKeyPrefix returns the prefix of keys of items of type t which are not
+// collected.
+func typeKeyPrefix(t osmpbf.MemberType) []byte {
+	return []byte{byte(t)}
+}
+
+// prefixed returns key with a prefix, like collectedKeyPrefix.
+func prefixed(prefix, key []byte) []byte {
+	return append(append(make([]byte, 0, len(prefix)+len(key)), prefix...), key...)
+}
+
+var formatKey = []byte("meta:keyformat")
+
+// format is the version of the storage format stored under formatKey.
+// Databases without it use JSON keys and values. Version 2 uses binary keys
+// and JSON values, version 3 binary keys and values, version 4 binary keys
+// and type tagged binary values, version 5 adds the state of the run and
+// version 6 matched markers.
+const format = "6"
+
+// InitDB marks an empty levelDB with the current storage format. It refuses
+// databases in another format.
+func InitDB(db *leveldb.DB) error {
+	stored, err := db.Get(formatKey, nil)
+	if err == nil {
+		if string(stored) != format {
+			return fmt.Errorf("levelDB uses storage format %s, expected %s; delete it to start over", stored, format)
+		}
+		return nil
+	}
+	if err != leveldb.ErrNotFound {
+		return err
+	}
+	iter := db.NewIterator(nil, nil)
+	empty := !iter.First()
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if !empty {
+		return errors.New("levelDB uses the old JSON key format; delete it to start over")
+	}
+	return db.Put(formatKey, []byte(format), nil)
 }
 
 // KeyValue returns key and value for a levelDB record.
@@ -41,15 +108,60 @@ func KeyValue(v interface{}) (key, value []byte, err error) {
 		return nil, nil, fmt.Errorf("unknown type %T", v)
 	}
 
-	if key, err = json.Marshal(dbKey); err != nil {
-		return nil, nil, err
+	key = dbKey.Bytes()
+	value, err = codec.Append(nil, v)
+	return
+}
+
+// entityType returns the type of a Node, Way or Relation.
+func entityType(v interface{}) osmpbf.MemberType {
+	switch v.(type) {
+	case *osmpbf.Way:
+		return osmpbf.WayType
+	case *osmpbf.Relation:
+		return osmpbf.RelationType
 	}
+	return osmpbf.NodeType
+}
 
-	if value, err = json.Marshal(v); err != nil {
-		return nil, nil, err
+// entity is a decoded levelDB record along with its key.
+type entity struct {
+	key DBKey
+	v   interface{}
+}
+
+// decodeEntity decodes a levelDB record into a Node, Way or Relation of the
+// type of its key.
+func decodeEntity(key, value []byte) (entity, error) {
+	var (
+		e   entity
+		err error
+	)
+	if e.key, err = ParseDBKey(key); err != nil {
+		return e, err
+	}
+	if e.v, err = codec.Decode(value); err != nil {
+		return e, fmt.Errorf("%s: %v", typeRef(e.key.Type, e.key.ID), err)
 	}
+	if entityType(e.v) != e.key.Type {
+		return e, fmt.Errorf("%s: stored as %T", typeRef(e.key.Type, e.key.ID), e.v)
+	}
+	return e, nil
+}
 
-	return
+// lookup returns a stored Node, Way or Relation, collected or not.
+// It returns leveldb.ErrNotFound if there is none.
+func (c *Command) lookup(t osmpbf.MemberType, id int64) (interface{}, error) {
+	key := (&DBKey{Type: t, ID: id}).Bytes()
+	value, err := c.dbGet(key)
+	if err == leveldb.ErrNotFound {
+		value, err = c.dbGet(prefixed(collectedKeyPrefix, key))
+	}
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeEntity(key, value)
+	return e.v, err
 }
 
 func (c *Command) dbPut(key, value []byte) error {
@@ -0,0 +1,46 @@
+package run_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/qedus/osmpbf"
+)
+
+// sortedKeys are in the order the output is expected in.
+var sortedKeys = []run.DBKey{
+	{Type: osmpbf.NodeType, ID: -10},
+	{Type: osmpbf.NodeType, ID: -1},
+	{Type: osmpbf.NodeType, ID: 0},
+	{Type: osmpbf.NodeType, ID: 9},
+	{Type: osmpbf.NodeType, ID: 10},
+	{Type: osmpbf.NodeType, ID: 1 << 40},
+	{Type: osmpbf.WayType, ID: 1},
+	{Type: osmpbf.RelationType, ID: -5},
+	{Type: osmpbf.RelationType, ID: 62422},
+}
+
+func TestDBKeyOrder(t *testing.T) {
+	keys := make([][]byte, len(sortedKeys))
+	for i := range sortedKeys {
+		keys[i] = sortedKeys[len(sortedKeys)-1-i].Bytes()
+	}
+	sort.Sort(byBytes(keys))
+	for i, b := range keys {
+		actual, err := run.ParseDBKey(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != sortedKeys[i] {
+			t.Errorf("Expected %v, actual %v", sortedKeys[i], actual)
+		}
+	}
+}
+
+type byBytes [][]byte
+
+func (s byBytes) Len() int           { return len(s) }
+func (s byBytes) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byBytes) Less(i, j int) bool { return bytes.Compare(s[i], s[j]) < 0 }
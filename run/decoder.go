@@ -0,0 +1,127 @@
+package run
+
+import (
+	"errors"
+	"io"
+	"log"
+
+	"github.com/ambiweb/osm-pbf-filter/osmxml"
+	"github.com/qedus/osmpbf"
+)
+
+// Decoder decodes OSM entities one by one. Decode returns pointers to
+// osmpbf.Node, osmpbf.Way or osmpbf.Relation structs and io.EOF at the end
+// of the input, as osmpbf.Decoder does.
+type Decoder interface {
+	Decode() (interface{}, error)
+}
+
+// ChangeDecoder decodes changes of OSM entities one by one, as osmxml.Decoder
+// does with OsmChange files.
+type ChangeDecoder interface {
+	DecodeChange() (osmxml.Action, interface{}, error)
+}
+
+// OpenFunc opens an input for decoding. If the returned Decoder is also an
+// io.Closer, it is closed at the end of the input.
+type OpenFunc func() (Decoder, error)
+
+// MultiDecoder decodes several inputs in turn, opening each one when the
+// previous one is done, so every input starts with its own OSMHeader.
+type MultiDecoder struct {
+	// LogHeaders logs the header of each input as it is opened, for the
+	// first of decoders reading the input more than once.
+	LogHeaders bool
+
+	inputs []OpenFunc
+	dec    Decoder
+	header *osmpbf.Header
+}
+
+// NewMultiDecoder returns a decoder of the inputs.
+func NewMultiDecoder(inputs ...OpenFunc) *MultiDecoder {
+	return &MultiDecoder{inputs: inputs}
+}
+
+// Decode returns the next entity of the current input.
+func (md *MultiDecoder) Decode() (interface{}, error) {
+	for {
+		if err := md.open(); err != nil {
+			return nil, err
+		}
+		v, err := md.dec.Decode()
+		if err != io.EOF {
+			return v, err
+		}
+		if err := md.Close(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// DecodeChange returns the next change of the current input, which must be
+// a ChangeDecoder.
+func (md *MultiDecoder) DecodeChange() (osmxml.Action, interface{}, error) {
+	for {
+		if err := md.open(); err != nil {
+			return "", nil, err
+		}
+		cd, ok := md.dec.(ChangeDecoder)
+		if !ok {
+			return "", nil, errors.New("input has no changes")
+		}
+		action, v, err := cd.DecodeChange()
+		if err != io.EOF {
+			return action, v, err
+		}
+		if err := md.Close(); err != nil {
+			return "", nil, err
+		}
+	}
+}
+
+// open opens the next input if there is no current one. It returns io.EOF
+// after the last input.
+func (md *MultiDecoder) open() error {
+	if md.dec != nil {
+		return nil
+	}
+	if len(md.inputs) == 0 {
+		return io.EOF
+	}
+	dec, err := md.inputs[0]()
+	if err != nil {
+		return err
+	}
+	md.inputs = md.inputs[1:]
+	md.dec = dec
+	if hd, ok := dec.(HeaderDecoder); ok {
+		h, err := hd.Header()
+		if err != nil {
+			return err
+		}
+		if md.LogHeaders {
+			log.Printf("Input header: %s", describeHeader(h))
+		}
+		md.header = mergeHeader(md.header, h)
+	}
+	return nil
+}
+
+// Header returns the headers of the inputs opened so far, merged.
+func (md *MultiDecoder) Header() (*osmpbf.Header, error) {
+	if md.header == nil {
+		return nil, errors.New("input has no header")
+	}
+	return md.header, nil
+}
+
+// Close closes the current input.
+func (md *MultiDecoder) Close() error {
+	dec := md.dec
+	md.dec = nil
+	if c, ok := dec.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
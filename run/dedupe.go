@@ -0,0 +1,60 @@
+package run
+
+import (
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// stale checks if an item of the same type and ID is stored with the same or
+// a newer version, as happens when several inputs overlap. Older versions
+// are deleted, so the item can be stored again.
+func (c *Command) stale(v interface{}) (bool, error) {
+	key, value, err := KeyValue(v)
+	if err != nil {
+		return false, err
+	}
+	version := infoOf(v).Version
+	keys := [][]byte{
+		key,
+		prefixed(collectedKeyPrefix, key),
+		prefixed(insideKeyPrefix, key),
+		prefixed(matchedKeyPrefix, key),
+	}
+	for _, k := range keys[:2] {
+		if value, err = c.dbGet(k); err == nil {
+			break
+		}
+	}
+	if err == leveldb.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	stored, err := decodeEntity(key, value)
+	if err != nil {
+		return false, err
+	}
+	if infoOf(stored.v).Version >= version {
+		return true, nil
+	}
+	for _, k := range keys {
+		if err := c.dbDelete(k); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// infoOf returns metadata of a Node, Way or Relation.
+func infoOf(v interface{}) osmpbf.Info {
+	switch v := v.(type) {
+	case *osmpbf.Node:
+		return v.Info
+	case *osmpbf.Way:
+		return v.Info
+	case *osmpbf.Relation:
+		return v.Info
+	}
+	return osmpbf.Info{}
+}
@@ -0,0 +1,93 @@
+package run_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/ambiweb/osm-pbf-filter/tags"
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+func node(id int64, version int32, name string) *osmpbf.Node {
+	return &osmpbf.Node{ID: id, Tags: map[string]string{"amenity": "cafe", "name": name}, Info: osmpbf.Info{Version: version}}
+}
+
+var dedupeTests = []struct {
+	inputs   [2][]interface{}
+	expected []string
+	excluded []string
+}{
+	// newer in the second input
+	{
+		[2][]interface{}{{node(1, 1, "old")}, {node(1, 2, "new")}},
+		[]string{`"name":"new"`},
+		[]string{`"name":"old"`},
+	},
+	// newer in the first input
+	{
+		[2][]interface{}{{node(1, 3, "new")}, {node(1, 2, "old")}},
+		[]string{`"name":"new"`},
+		[]string{`"name":"old"`},
+	},
+	// the same version in both inputs is written once
+	{
+		[2][]interface{}{{node(1, 2, "first"), node(2, 1, "only")}, {node(1, 2, "second")}},
+		[]string{`"name":"first"`, `"name":"only"`},
+		[]string{`"name":"second"`},
+	},
+	// a newer version no longer matching replaces a matching one
+	{
+		[2][]interface{}{{node(1, 1, "old")}, {&osmpbf.Node{ID: 1, Tags: map[string]string{}, Info: osmpbf.Info{Version: 2}}}},
+		nil,
+		[]string{`"ID":1`},
+	},
+}
+
+func TestDedupe(t *testing.T) {
+	x, err := tags.ParseExpr("amenity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range dedupeTests {
+		var inputs []run.OpenFunc
+		for _, input := range tt.inputs {
+			input := input
+			inputs = append(inputs, func() (run.Decoder, error) {
+				d := sliceDecoder(input)
+				return &d, nil
+			})
+		}
+		db, err := leveldb.Open(storage.NewMemStorage(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &run.Command{LevelDB: db, PBFDecoder: run.NewMultiDecoder(inputs...)}
+		c.TagsMatcher = x
+		c.Dedupe = true
+		c.Format = run.FormatJSON
+		var b bytes.Buffer
+		c.Stdout = &b
+		if err := run.Run(c); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+		actual := b.String()
+		if n := len(strings.Fields(refs(t, b.String()))); n != len(tt.expected) {
+			t.Errorf("%d: expected %d items, actual %d: %s", i, len(tt.expected), n, actual)
+		}
+		for _, s := range tt.expected {
+			if !strings.Contains(actual, s) {
+				t.Errorf("%d: expected %s in %s", i, s, actual)
+			}
+		}
+		for _, s := range tt.excluded {
+			if strings.Contains(actual, s) {
+				t.Errorf("%d: unexpected %s in %s", i, s, actual)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package run
+
+import (
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// EachNode calls fn for every collected node in ID order.
+func (c *Command) EachNode(fn func(*osmpbf.Node) error) error {
+	return c.eachCollected(osmpbf.NodeType, func(v interface{}) error {
+		return fn(v.(*osmpbf.Node))
+	})
+}
+
+// EachWay calls fn for every collected way in ID order.
+func (c *Command) EachWay(fn func(*osmpbf.Way) error) error {
+	return c.eachCollected(osmpbf.WayType, func(v interface{}) error {
+		return fn(v.(*osmpbf.Way))
+	})
+}
+
+// EachRelation calls fn for every collected relation in ID order.
+func (c *Command) EachRelation(fn func(*osmpbf.Relation) error) error {
+	return c.eachCollected(osmpbf.RelationType, func(v interface{}) error {
+		return fn(v.(*osmpbf.Relation))
+	})
+}
+
+// eachCollected calls fn for every collected item of type t.
+func (c *Command) eachCollected(t osmpbf.MemberType, fn func(interface{}) error) error {
+	prefix := prefixed(collectedKeyPrefix, typeKeyPrefix(t))
+	iter := c.LevelDB.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		e, err := decodeEntity(iter.Key()[len(collectedKeyPrefix):], iter.Value())
+		if err != nil {
+			return err
+		}
+		if err := fn(e.v); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
@@ -0,0 +1,77 @@
+package run_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+func newCommand(t *testing.T) *run.Command {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.InitDB(db); err != nil {
+		t.Fatal(err)
+	}
+	return &run.Command{LevelDB: db, Closure: run.ClosureCompleteWays, Missing: run.MissingSkip}
+}
+
+func TestEach(t *testing.T) {
+	c := newCommand(t)
+	defer c.LevelDB.Close()
+	put := []interface{}{
+		&osmpbf.Node{ID: 1, Tags: map[string]string{}},
+		&osmpbf.Node{ID: 2, Tags: map[string]string{}},
+		&osmpbf.Node{ID: 3, Tags: map[string]string{}},
+		&osmpbf.Way{ID: 10, Tags: map[string]string{}, NodeIDs: []int64{1, 2}},
+	}
+	for _, v := range put {
+		if err := c.Put(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &osmpbf.Relation{ID: 100, Tags: map[string]string{"type": "route"}, Members: []osmpbf.Member{
+		{ID: 10, Type: osmpbf.WayType, Role: ""},
+	}}
+	if err := c.Collect(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CollectRelated(); err != nil {
+		t.Fatal(err)
+	}
+
+	var nodes, ways, relations []int64
+	if err := c.EachNode(func(n *osmpbf.Node) error {
+		nodes = append(nodes, n.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EachWay(func(w *osmpbf.Way) error {
+		ways = append(ways, w.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EachRelation(func(r *osmpbf.Relation) error {
+		relations = append(relations, r.ID)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ expected, actual []int64 }{
+		{[]int64{1, 2}, nodes},
+		{[]int64{10}, ways},
+		{[]int64{100}, relations},
+	} {
+		if !reflect.DeepEqual(tt.expected, tt.actual) {
+			t.Errorf("Expected %v, actual %v", tt.expected, tt.actual)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package run
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ambiweb/osm-pbf-filter/geojson"
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// geojsonWriter writes items as features of a GeoJSON FeatureCollection.
+// Nodes become Points, ways LineStrings or, when closed and tagged as areas,
+// Polygons, multipolygon and boundary relations MultiPolygons. Positions are
+// looked up in c. Entities without tags which were not matched, being only
+// parts of other entities, and entities without a geometry are skipped.
+type geojsonWriter struct {
+	c *Command
+	w *geojson.Writer
+}
+
+func (c *Command) newGeoJSONWriter() Writer {
+	return &geojsonWriter{c: c, w: geojson.NewWriter(c.Stdout)}
+}
+
+func (gw *geojsonWriter) Write(v interface{}) error {
+	f, err := gw.c.feature(v)
+	if err != nil || f == nil {
+		return err
+	}
+	return gw.w.Write(f)
+}
+
+func (gw *geojsonWriter) Close() error {
+	return gw.w.Close()
+}
+
+func (c *Command) feature(v interface{}) (*geojson.Feature, error) {
+	var (
+		f   *geojson.Feature
+		err error
+	)
+	switch v := v.(type) {
+	case *osmpbf.Node:
+		if skip, err := c.skipFeature(osmpbf.NodeType, v.ID, v.Tags); skip || err != nil {
+			return nil, err
+		}
+		f = geojson.NewFeature(fmt.Sprintf("n%d", v.ID), geojson.NewPoint(geojson.Point{v.Lon, v.Lat}), v.Tags)
+		f.Properties["@type"] = "node"
+		f.Properties["@id"] = v.ID
+	case *osmpbf.Way:
+		if skip, err := c.skipFeature(osmpbf.WayType, v.ID, v.Tags); skip || err != nil {
+			return nil, err
+		}
+		var g *geojson.Geometry
+		if g, err = c.wayGeometry(v); err != nil || g == nil {
+			return nil, err
+		}
+		f = geojson.NewFeature(fmt.Sprintf("w%d", v.ID), g, v.Tags)
+		f.Properties["@type"] = "way"
+		f.Properties["@id"] = v.ID
+	case *osmpbf.Relation:
+		if t := v.Tags["type"]; t != "multipolygon" && t != "boundary" {
+			return nil, nil
+		}
+		var g *geojson.Geometry
+		if g, err = c.relationGeometry(v); err != nil || g == nil {
+			return nil, err
+		}
+		f = geojson.NewFeature(fmt.Sprintf("r%d", v.ID), g, v.Tags)
+		f.Properties["@type"] = "relation"
+		f.Properties["@id"] = v.ID
+	}
+	return f, nil
+}
+
+// skipFeature reports if an item is only collected as a part of others: it
+// has no tags and was not matched.
+func (c *Command) skipFeature(t osmpbf.MemberType, id int64, tags map[string]string) (bool, error) {
+	if len(tags) > 0 {
+		return false, nil
+	}
+	key := (&DBKey{Type: t, ID: id}).Bytes()
+	matched, err := c.LevelDB.Has(prefixed(matchedKeyPrefix, key), nil)
+	return !matched, err
+}
+
+func (c *Command) wayGeometry(w *osmpbf.Way) (*geojson.Geometry, error) {
+	line, err := c.wayLine(w)
+	if err != nil || len(line.Points) < 2 {
+		return nil, err
+	}
+	if line.Closed() && isArea(w.Tags) {
+		return geojson.NewPolygon(geojson.Polygon(line.Points)), nil
+	}
+	return geojson.NewLineString(line.Points), nil
+}
+
+func (c *Command) relationGeometry(r *osmpbf.Relation) (*geojson.Geometry, error) {
+	var outer, inner []geojson.Line
+	for _, m := range r.Members {
+		if m.Type != osmpbf.WayType {
+			continue
+		}
+		v, err := c.lookup(osmpbf.WayType, m.ID)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, err := c.wayLine(v.(*osmpbf.Way))
+		if err != nil {
+			return nil, err
+		}
+		if m.Role == "inner" {
+			inner = append(inner, line)
+		} else {
+			outer = append(outer, line)
+		}
+	}
+	outerRings, err := geojson.Rings(outer)
+	if err != nil {
+		log.Printf("Skipping relation %d: %v", r.ID, err)
+		return nil, nil
+	}
+	innerRings, err := geojson.Rings(inner)
+	if err != nil {
+		log.Printf("Skipping relation %d: %v", r.ID, err)
+		return nil, nil
+	}
+	if len(outerRings) == 0 {
+		return nil, nil
+	}
+	return geojson.NewMultiPolygon(geojson.MultiPolygon(outerRings, innerRings)), nil
+}
+
+// wayLine looks up positions of way nodes. Nodes missing in levelDB are left out.
+func (c *Command) wayLine(w *osmpbf.Way) (geojson.Line, error) {
+	var line geojson.Line
+	for _, id := range w.NodeIDs {
+		v, err := c.lookup(osmpbf.NodeType, id)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return line, err
+		}
+		n := v.(*osmpbf.Node)
+		line.IDs = append(line.IDs, id)
+		line.Points = append(line.Points, geojson.Point{n.Lon, n.Lat})
+	}
+	return line, nil
+}
+
+// areaKeys lists keys that make a closed way an area unless area=no is set.
+var areaKeys = map[string]bool{
+	"aeroway":  true,
+	"amenity":  true,
+	"building": true,
+	"historic": true,
+	"landuse":  true,
+	"leisure":  true,
+	"man_made": true,
+	"military": true,
+	"natural":  true,
+	"office":   true,
+	"place":    true,
+	"shop":     true,
+	"tourism":  true,
+	"water":    true,
+}
+
+// isArea reports if a closed way with tags is an area rather than a line.
+func isArea(tags map[string]string) bool {
+	switch tags["area"] {
+	case "yes":
+		return true
+	case "no":
+		return false
+	}
+	if tags["natural"] == "coastline" {
+		return false
+	}
+	for k := range tags {
+		if areaKeys[k] {
+			return true
+		}
+	}
+	return false
+}
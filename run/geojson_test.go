@@ -0,0 +1,41 @@
+package run_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+)
+
+var geojsonTests = []struct {
+	expr     string
+	ids      string
+	expected []string
+	excluded []string
+}{
+	// untagged items are features when selected, not as parts of others
+	{"", "w11", []string{`"id":"w11"`, `"id":"n3"`}, []string{`"id":"n4"`}},
+	{"", "n4", []string{`"id":"n4"`}, nil},
+	{"building", "", []string{`"id":"w10"`}, []string{`"id":"n1"`, `"id":"n2"`}},
+}
+
+func TestGeoJSON(t *testing.T) {
+	for _, tt := range geojsonTests {
+		for _, strategy := range []string{run.StrategyDB, run.StrategyTwoPass} {
+			output, err := selectInput(t, strategy, run.ClosureCompleteWays, run.MissingSkip, tt.expr, tt.ids, run.FormatGeoJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.expected {
+				if !strings.Contains(output, s) {
+					t.Errorf("%s %s %s: expected %s in %s", tt.expr, tt.ids, strategy, s, output)
+				}
+			}
+			for _, s := range tt.excluded {
+				if strings.Contains(output, s) {
+					t.Errorf("%s %s %s: unexpected %s in %s", tt.expr, tt.ids, strategy, s, output)
+				}
+			}
+		}
+	}
+}
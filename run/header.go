@@ -0,0 +1,137 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// HeaderDecoder is a Decoder of an input with an OSMHeader block, as
+// osmpbf.Decoder is.
+type HeaderDecoder interface {
+	Header() (*osmpbf.Header, error)
+}
+
+// putHeader stores the header of the input, if it has one.
+func (c *Command) putHeader() error {
+	hd, ok := c.PBFDecoder.(HeaderDecoder)
+	if !ok {
+		return nil
+	}
+	h, err := hd.Header()
+	if err != nil {
+		// inputs without header, e.g. OSM XML
+		return nil
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return c.dbPut(headerKey, b)
+}
+
+// header returns the header of the input, stored by PutData or read by the
+// decoder, or nil if there is none.
+func (c *Command) header() *osmpbf.Header {
+	if c.LevelDB != nil {
+		b, err := c.dbGet(headerKey)
+		if err == nil {
+			h := &osmpbf.Header{}
+			if err := json.Unmarshal(b, h); err == nil {
+				return h
+			}
+		} else if err != leveldb.ErrNotFound {
+			log.Printf("Reading the input header: %v", err)
+		}
+	}
+	if hd, ok := c.PBFDecoder.(HeaderDecoder); ok {
+		if h, err := hd.Header(); err == nil {
+			return h
+		}
+	}
+	return nil
+}
+
+// mergeHeader combines the header of an input with that of the inputs before
+// it. The bounding box covers both; other fields are kept if they agree.
+func mergeHeader(h, next *osmpbf.Header) *osmpbf.Header {
+	if h == nil {
+		m := *next
+		return &m
+	}
+	m := *h
+	if m.BoundingBox != nil && next.BoundingBox != nil {
+		b := *m.BoundingBox
+		nb := next.BoundingBox
+		if nb.Left < b.Left {
+			b.Left = nb.Left
+		}
+		if nb.Right > b.Right {
+			b.Right = nb.Right
+		}
+		if nb.Bottom < b.Bottom {
+			b.Bottom = nb.Bottom
+		}
+		if nb.Top > b.Top {
+			b.Top = nb.Top
+		}
+		m.BoundingBox = &b
+	} else {
+		m.BoundingBox = nil
+	}
+	if strings.Join(m.RequiredFeatures, ",") != strings.Join(next.RequiredFeatures, ",") {
+		m.RequiredFeatures = nil
+	}
+	if strings.Join(m.OptionalFeatures, ",") != strings.Join(next.OptionalFeatures, ",") {
+		m.OptionalFeatures = nil
+	}
+	if m.WritingProgram != next.WritingProgram {
+		m.WritingProgram = ""
+	}
+	if m.Source != next.Source {
+		m.Source = ""
+	}
+	if !m.OsmosisReplicationTimestamp.Equal(next.OsmosisReplicationTimestamp) ||
+		m.OsmosisReplicationSequenceNumber != next.OsmosisReplicationSequenceNumber ||
+		m.OsmosisReplicationBaseUrl != next.OsmosisReplicationBaseUrl {
+		m.OsmosisReplicationTimestamp = time.Time{}
+		m.OsmosisReplicationSequenceNumber = 0
+		m.OsmosisReplicationBaseUrl = ""
+	}
+	return &m
+}
+
+// describeHeader returns the fields of a header the input has, for logs.
+func describeHeader(h *osmpbf.Header) string {
+	var s []string
+	if h.WritingProgram != "" {
+		s = append(s, "written by "+h.WritingProgram)
+	}
+	if h.Source != "" {
+		s = append(s, "source "+h.Source)
+	}
+	if b := h.BoundingBox; b != nil {
+		s = append(s, fmt.Sprintf("bbox %.7f,%.7f,%.7f,%.7f", b.Left, b.Bottom, b.Right, b.Top))
+	}
+	if len(h.OptionalFeatures) > 0 {
+		s = append(s, "features "+strings.Join(h.OptionalFeatures, ","))
+	}
+	if !h.OsmosisReplicationTimestamp.IsZero() {
+		s = append(s, "replicated "+h.OsmosisReplicationTimestamp.UTC().Format(time.RFC3339))
+	}
+	if h.OsmosisReplicationSequenceNumber != 0 {
+		s = append(s, fmt.Sprintf("sequence %d", h.OsmosisReplicationSequenceNumber))
+	}
+	if h.OsmosisReplicationBaseUrl != "" {
+		s = append(s, "from "+h.OsmosisReplicationBaseUrl)
+	}
+	if len(s) == 0 {
+		return "empty"
+	}
+	return strings.Join(s, ", ")
+}
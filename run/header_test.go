@@ -0,0 +1,77 @@
+package run_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/qedus/osmpbf"
+)
+
+// headerDecoder is a sliceDecoder of an input with a header.
+type headerDecoder struct {
+	sliceDecoder
+	header *osmpbf.Header
+}
+
+func (d *headerDecoder) Header() (*osmpbf.Header, error) {
+	return d.header, nil
+}
+
+var replicated = time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+
+var headers = []*osmpbf.Header{
+	{
+		BoundingBox:                      &osmpbf.BoundingBox{Left: 13, Right: 14, Top: 53, Bottom: 52},
+		WritingProgram:                   "osmium",
+		OsmosisReplicationTimestamp:      replicated,
+		OsmosisReplicationSequenceNumber: 4711,
+	},
+	{
+		BoundingBox:                      &osmpbf.BoundingBox{Left: 11, Right: 13.5, Top: 52.5, Bottom: 48},
+		WritingProgram:                   "osmium",
+		OsmosisReplicationTimestamp:      replicated,
+		OsmosisReplicationSequenceNumber: 4712,
+	},
+}
+
+func TestHeader(t *testing.T) {
+	c := newCommand(t)
+	defer c.LevelDB.Close()
+	var inputs []run.OpenFunc
+	for i, h := range headers {
+		h := h
+		d := sliceDecoder{&osmpbf.Node{ID: int64(i + 1), Tags: map[string]string{}}}
+		inputs = append(inputs, func() (run.Decoder, error) {
+			return &headerDecoder{d, h}, nil
+		})
+	}
+	dec := run.NewMultiDecoder(inputs...)
+	c.PBFDecoder = dec
+	c.Dedupe = true
+	c.Format = run.FormatOSM
+	var b bytes.Buffer
+	c.Stdout = &b
+	if err := run.Run(c); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &osmpbf.Header{
+		BoundingBox:    &osmpbf.BoundingBox{Left: 11, Right: 14, Top: 53, Bottom: 48},
+		WritingProgram: "osmium",
+	}
+	actual, err := dec.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %+v, actual %+v", expected, actual)
+	}
+	bounds := `<bounds minlat="48" minlon="11" maxlat="53" maxlon="14"></bounds>`
+	if !strings.Contains(b.String(), bounds) {
+		t.Errorf("Expected %s in %s", bounds, b.String())
+	}
+}
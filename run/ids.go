@@ -0,0 +1,120 @@
+package run
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/qedus/osmpbf"
+)
+
+// IDs is a set of typed IDs selecting items, e.g. from a QA report or an
+// earlier run. IDs are kept sorted by type to stay compact for long lists.
+type IDs struct {
+	ids [3][]int64
+}
+
+// Parse adds IDs separated by commas or spaces, each an ID prefixed with
+// its type, n, w or r, e.g. "n1,w12345,r62422".
+func (s *IDs) Parse(list string) error {
+	for _, f := range strings.FieldsFunc(list, isIDSeparator) {
+		t, id, err := parseID(f)
+		if err != nil {
+			return err
+		}
+		s.ids[t] = append(s.ids[t], id)
+	}
+	s.sort()
+	return nil
+}
+
+// Read adds the IDs of lines of r, as Parse does. Text after # is a comment.
+// Lines may be of any length.
+func (s *IDs) Read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	var token []byte
+	comment := false
+	for line := 1; ; {
+		c, err := br.ReadByte()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		end := err == io.EOF
+		if (end || c == '#' || isIDSeparator(rune(c))) && len(token) > 0 {
+			t, id, err := parseID(string(token))
+			if err != nil {
+				return fmt.Errorf("line %d: %v", line, err)
+			}
+			s.ids[t] = append(s.ids[t], id)
+			token = token[:0]
+		}
+		if end {
+			break
+		}
+		switch {
+		case c == '\n':
+			line++
+			comment = false
+		case c == '#':
+			comment = true
+		case !comment && !isIDSeparator(rune(c)):
+			token = append(token, c)
+		}
+	}
+	s.sort()
+	return nil
+}
+
+func isIDSeparator(c rune) bool {
+	return c == ',' || c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// parseID parses an ID prefixed with its type.
+func parseID(s string) (osmpbf.MemberType, int64, error) {
+	var t osmpbf.MemberType
+	switch s[0] {
+	case 'n', 'N':
+		t = osmpbf.NodeType
+	case 'w', 'W':
+		t = osmpbf.WayType
+	case 'r', 'R':
+		t = osmpbf.RelationType
+	default:
+		return 0, 0, fmt.Errorf("invalid ID %q, expected n, w or r followed by a number", s)
+	}
+	id, err := strconv.ParseInt(s[1:], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid ID %q, expected n, w or r followed by a number", s)
+	}
+	return t, id, nil
+}
+
+// sort sorts the IDs of each type and drops duplicates.
+func (s *IDs) sort() {
+	for t, ids := range s.ids {
+		sort.Sort(int64s(ids))
+		n := 0
+		for i, id := range ids {
+			if i == 0 || id != ids[n-1] {
+				ids[n] = id
+				n++
+			}
+		}
+		s.ids[t] = ids[:n]
+	}
+}
+
+// Has checks if the set has the ID of type t.
+func (s *IDs) Has(t osmpbf.MemberType, id int64) bool {
+	ids := s.ids[t]
+	i := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+	return i < len(ids) && ids[i] == id
+}
+
+// Len returns the number of IDs.
+func (s *IDs) Len() int {
+	return len(s.ids[osmpbf.NodeType]) + len(s.ids[osmpbf.WayType]) + len(s.ids[osmpbf.RelationType])
+}
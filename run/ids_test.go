@@ -0,0 +1,92 @@
+package run_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/qedus/osmpbf"
+)
+
+var idsTests = []struct {
+	list     string
+	typ      osmpbf.MemberType
+	id       int64
+	expected bool
+}{
+	{"n1,w12345,r62422", osmpbf.RelationType, 62422, true},
+	{"n1,w12345,r62422", osmpbf.NodeType, 12345, false},
+	{"n1 N2\tn-3", osmpbf.NodeType, -3, true},
+	{"w5,w3,w5,w1", osmpbf.WayType, 3, true},
+	{"", osmpbf.NodeType, 0, false},
+}
+
+func TestIDs(t *testing.T) {
+	for _, tt := range idsTests {
+		var ids run.IDs
+		if err := ids.Parse(tt.list); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.list, err)
+			continue
+		}
+		if actual := ids.Has(tt.typ, tt.id); actual != tt.expected {
+			t.Errorf("%s: expected %v, actual %v", tt.list, tt.expected, actual)
+		}
+	}
+}
+
+func TestIDsRead(t *testing.T) {
+	var ids run.IDs
+	if err := ids.Read(strings.NewReader("# from a QA report\nw10 r62422 # checked\n\nn1,n1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if ids.Len() != 3 {
+		t.Errorf("Expected %v, actual %v", 3, ids.Len())
+	}
+	if err := ids.Read(strings.NewReader("n1\nx2\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error on line 2, actual %v", err)
+	}
+
+	// a long list on a single line
+	var long run.IDs
+	list := strings.Repeat("w1234567,", 100000) + "n1"
+	if err := long.Read(strings.NewReader(list)); err != nil {
+		t.Fatal(err)
+	}
+	if !long.Has(osmpbf.NodeType, 1) || long.Len() != 2 {
+		t.Errorf("Expected %v, actual %v", 2, long.Len())
+	}
+}
+
+func TestIDsSelect(t *testing.T) {
+	for _, tt := range []struct {
+		expr     string
+		ids      string
+		expected []string
+		excluded []string
+	}{
+		{"", "w11", []string{`"ID":11`, `"ID":4`}, []string{`"ID":10`, `"ID":1,`}},
+		{"amenity", "r100", []string{`"ID":100`, `"ID":10`, `"ID":1,`}, []string{`"ID":11`}},
+	} {
+		expected, err := selectInput(t, run.StrategyDB, run.ClosureCompleteWays, run.MissingSkip, tt.expr, tt.ids, run.FormatJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := selectInput(t, run.StrategyTwoPass, run.ClosureCompleteWays, run.MissingSkip, tt.expr, tt.ids, run.FormatJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("%s: expected %v, actual %v", tt.ids, expected, actual)
+		}
+		for _, s := range tt.expected {
+			if !strings.Contains(actual, s) {
+				t.Errorf("%s: expected %s in %v", tt.ids, s, actual)
+			}
+		}
+		for _, s := range tt.excluded {
+			if strings.Contains(actual, s) {
+				t.Errorf("%s: unexpected %s in %v", tt.ids, s, actual)
+			}
+		}
+	}
+}
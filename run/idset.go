@@ -0,0 +1,33 @@
+package run
+
+import (
+	"sort"
+)
+
+// idSet is a set of IDs of one type.
+type idSet map[int64]struct{}
+
+func (s idSet) add(id int64) {
+	s[id] = struct{}{}
+}
+
+func (s idSet) has(id int64) bool {
+	_, ok := s[id]
+	return ok
+}
+
+// sorted returns the IDs in ascending order.
+func (s idSet) sorted() []int64 {
+	ids := make([]int64, 0, len(s))
+	for id := range s {
+		ids = append(ids, id)
+	}
+	sort.Sort(int64s(ids))
+	return ids
+}
+
+type int64s []int64
+
+func (s int64s) Len() int           { return len(s) }
+func (s int64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,68 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	"github.com/qedus/osmpbf"
+)
+
+// Missing member policies tell what to do with members of collected items
+// which are not in the input, as happens with regional extracts.
+const (
+	// MissingSkip leaves missing members out silently.
+	MissingSkip = "skip"
+	// MissingWarn leaves missing members out and logs each of them.
+	MissingWarn = "warn"
+	// MissingFail aborts the run.
+	MissingFail = "fail"
+)
+
+// maxMissingIDs limits the number of missing IDs kept for the summary.
+const maxMissingIDs = 100
+
+// missingSummary counts missing members by type and keeps the first IDs.
+type missingSummary struct {
+	counts [3]int
+	ids    []string
+}
+
+func (c *Command) missingMember(t osmpbf.MemberType, id int64) error {
+	ref := typeRef(t, id)
+	switch c.Missing {
+	case MissingFail:
+		return fmt.Errorf("member %s is missing in the input", ref)
+	case MissingWarn:
+		log.Printf("Member %s is missing in the input", ref)
+	}
+	c.missing.counts[t]++
+	if len(c.missing.ids) < maxMissingIDs {
+		c.missing.ids = append(c.missing.ids, ref)
+	}
+	return nil
+}
+
+func (s *missingSummary) log() {
+	total := s.counts[osmpbf.NodeType] + s.counts[osmpbf.WayType] + s.counts[osmpbf.RelationType]
+	if total == 0 {
+		return
+	}
+	var b bytes.Buffer
+	for i, ref := range s.ids {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(ref)
+	}
+	if total > len(s.ids) {
+		b.WriteString(", ...")
+	}
+	log.Printf("Missing members: %d nodes, %d ways, %d relations: %s",
+		s.counts[osmpbf.NodeType], s.counts[osmpbf.WayType], s.counts[osmpbf.RelationType], b.String())
+}
+
+// typeRef returns a short reference to an item, e.g. w123 for way 123.
+func typeRef(t osmpbf.MemberType, id int64) string {
+	return fmt.Sprintf("%c%d", "nwr"[t], id)
+}
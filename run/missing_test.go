@@ -0,0 +1,52 @@
+package run_test
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+)
+
+var missingTests = []struct {
+	missing  string
+	expr     string
+	expected string // output, or the error if the run fails
+	fails    bool
+	logged   string
+}{
+	// relations 101 and 102 are members of each other
+	{run.MissingSkip, "type=route_master", "n3 n4 w11 r101 r102", false, ""},
+	{run.MissingWarn, "type=route_master", "n3 n4 w11 r101 r102", false, "Member w999 is missing"},
+	{run.MissingFail, "type=route_master", "member w999 is missing", true, ""},
+	{run.MissingSkip, "type=site", "n1 n2 n3 w10 r100 r103", false, ""},
+	{run.MissingWarn, "type=site", "n1 n2 n3 w10 r100 r103", false, "Member r998 is missing"},
+	{run.MissingFail, "type=site", "member r998 is missing", true, ""},
+	{run.MissingFail, "amenity", "n3", false, ""},
+}
+
+func TestMissing(t *testing.T) {
+	var b bytes.Buffer
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stderr)
+	for _, strategy := range []string{run.StrategyDB, run.StrategyTwoPass} {
+		for _, tt := range missingTests {
+			b.Reset()
+			actual, err := selectInput(t, strategy, run.ClosureCompleteWays, tt.missing, tt.expr, "", run.FormatJSON)
+			if err != nil {
+				actual = err.Error()
+			} else {
+				actual = refs(t, actual)
+			}
+			if (err != nil) != tt.fails || !tt.fails && actual != tt.expected || !strings.Contains(actual, tt.expected) {
+				t.Errorf("%s %s %s: expected %s, actual %s", strategy, tt.missing, tt.expr, tt.expected, actual)
+			}
+			logged := strings.Contains(b.String(), "is missing")
+			if logged != (tt.logged != "") || !strings.Contains(b.String(), tt.logged) {
+				t.Errorf("%s %s %s: expected %q in log, actual %q", strategy, tt.missing, tt.expr, tt.logged, b.String())
+			}
+		}
+	}
+}
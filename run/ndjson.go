@@ -0,0 +1,98 @@
+package run
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"time"
+
+	"github.com/qedus/osmpbf"
+)
+
+// record is an item in newline-delimited JSON. Every record has all fields;
+// those which do not apply to the type of the item are null.
+type record struct {
+	Type    string            `json:"type"`
+	ID      int64             `json:"id"`
+	Tags    map[string]string `json:"tags"`
+	Lat     *float64          `json:"lat"`
+	Lon     *float64          `json:"lon"`
+	Nodes   []int64           `json:"nodes"`
+	Members []recordMember    `json:"members"`
+	Info    recordInfo        `json:"info"`
+}
+
+type recordMember struct {
+	Type string `json:"type"`
+	Ref  int64  `json:"ref"`
+	Role string `json:"role"`
+}
+
+type recordInfo struct {
+	Version   int32  `json:"version"`
+	Timestamp string `json:"timestamp"`
+	Changeset int64  `json:"changeset"`
+	UID       int32  `json:"uid"`
+	User      string `json:"user"`
+	Visible   bool   `json:"visible"`
+}
+
+// typeNames are names of member types as OSM XML uses them.
+var typeNames = [...]string{
+	osmpbf.NodeType:     "node",
+	osmpbf.WayType:      "way",
+	osmpbf.RelationType: "relation",
+}
+
+// ndjsonWriter writes items as newline-delimited JSON, one record per line.
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+// NewNDJSONWriter returns a writer of newline-delimited JSON records with
+// the fields type, id, tags, lat, lon, nodes, members and info.
+func NewNDJSONWriter(w io.Writer) Writer {
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+func (nw *ndjsonWriter) Write(v interface{}) error {
+	var r record
+	switch v := v.(type) {
+	case *osmpbf.Node:
+		lat, lon := round7(v.Lat), round7(v.Lon)
+		r = record{Type: "node", ID: v.ID, Tags: v.Tags, Lat: &lat, Lon: &lon, Info: newRecordInfo(v.Info)}
+	case *osmpbf.Way:
+		r = record{Type: "way", ID: v.ID, Tags: v.Tags, Nodes: v.NodeIDs, Info: newRecordInfo(v.Info)}
+	case *osmpbf.Relation:
+		r = record{Type: "relation", ID: v.ID, Tags: v.Tags, Info: newRecordInfo(v.Info)}
+		r.Members = make([]recordMember, len(v.Members))
+		for i, m := range v.Members {
+			r.Members[i] = recordMember{Type: typeNames[m.Type], Ref: m.ID, Role: m.Role}
+		}
+	}
+	return nw.enc.Encode(r)
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
+
+func newRecordInfo(info osmpbf.Info) recordInfo {
+	ri := recordInfo{
+		Version:   info.Version,
+		Changeset: info.Changeset,
+		UID:       info.Uid,
+		User:      info.User,
+		Visible:   info.Visible,
+	}
+	if !info.Timestamp.IsZero() {
+		ri.Timestamp = info.Timestamp.UTC().Format(time.RFC3339)
+	}
+	return ri
+}
+
+// round7 rounds degrees to the 7 decimals of OSM data, dropping float noise
+// of the PBF decoding.
+func round7(deg float64) float64 {
+	return math.Floor(deg*1e7+0.5) / 1e7
+}
@@ -0,0 +1,36 @@
+package run
+
+import (
+	"io"
+
+	"github.com/ambiweb/osm-pbf-filter/osmxml"
+	"github.com/qedus/osmpbf"
+)
+
+// osmxmlWriter writes items with an osmxml.Encoder, with the bounds of the
+// input header taken as pbfWriter does.
+type osmxmlWriter struct {
+	enc    *osmxml.Encoder
+	header func() *osmpbf.Header
+}
+
+func newOSMXMLWriter(w io.Writer, header func() *osmpbf.Header) Writer {
+	return &osmxmlWriter{osmxml.NewEncoder(w), header}
+}
+
+func (ow *osmxmlWriter) Write(v interface{}) error {
+	ow.setHeader()
+	return ow.enc.Encode(v)
+}
+
+func (ow *osmxmlWriter) Close() error {
+	ow.setHeader()
+	return ow.enc.Close()
+}
+
+func (ow *osmxmlWriter) setHeader() {
+	if ow.header != nil {
+		ow.enc.SetHeader(ow.header())
+		ow.header = nil
+	}
+}
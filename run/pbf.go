@@ -1,5 +1,41 @@
 package run
 
+import (
+	"io"
+
+	"github.com/ambiweb/osm-pbf-filter/pbf"
+	"github.com/qedus/osmpbf"
+)
+
 func (c *Command) decodePBF() (interface{}, error) {
 	return c.PBFDecoder.Decode()
 }
+
+// pbfWriter writes items with a pbf.Encoder. Collected keys sort by type and
+// ID, as the file requires. The header of the input is taken when writing
+// starts, as inputs are opened lazily.
+type pbfWriter struct {
+	enc    *pbf.Encoder
+	header func() *osmpbf.Header
+}
+
+func newPBFWriter(w io.Writer, header func() *osmpbf.Header) Writer {
+	return &pbfWriter{pbf.NewEncoder(w), header}
+}
+
+func (pw *pbfWriter) Write(v interface{}) error {
+	pw.setHeader()
+	return pw.enc.Encode(v)
+}
+
+func (pw *pbfWriter) Close() error {
+	pw.setHeader()
+	return pw.enc.Close()
+}
+
+func (pw *pbfWriter) setHeader() {
+	if pw.header != nil {
+		pw.enc.SetHeader(pw.header())
+		pw.header = nil
+	}
+}
@@ -0,0 +1,344 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Progress modes tell how a Progress reports.
+const (
+	// ProgressLog logs the start of each phase and the summary.
+	ProgressLog = "log"
+	// ProgressBar redraws a line with the state of the current phase, for
+	// terminals.
+	ProgressBar = "bar"
+	// ProgressJSON writes the state of the current phase as a JSON line
+	// every second and the summary as a last line.
+	ProgressJSON = "json"
+	// ProgressNone reports nothing.
+	ProgressNone = "none"
+)
+
+// Progress reports the phases of a run: items handled per second, input
+// read against its size with the time left, and at the end a summary of
+// counts and phase timings. A nil Progress logs the start of each phase.
+type Progress struct {
+	// counters of the current phase, first for 64-bit alignment
+	items int64
+	bytes int64
+
+	mode     string
+	w        io.Writer
+	total    int64
+	interval time.Duration
+
+	mu      sync.Mutex
+	current string
+	started time.Time
+	timings []phaseTiming
+	read    [3]int64
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type phaseTiming struct {
+	Phase   string  `json:"phase"`
+	Seconds float64 `json:"seconds"`
+}
+
+// NewProgress returns a Progress writing to w in mode. total is the size of
+// the input in bytes, 0 if unknown.
+func NewProgress(w io.Writer, mode string, total int64) *Progress {
+	interval := time.Second
+	if mode == ProgressBar {
+		interval = 200 * time.Millisecond
+	}
+	return &Progress{mode: mode, w: w, total: total, interval: interval}
+}
+
+// CountReader returns a reader counting the input read from r.
+func (p *Progress) CountReader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &countingReader{r, &p.bytes}
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	atomic.AddInt64(cr.n, int64(n))
+	return n, err
+}
+
+// phase ends the current phase and starts the named one. msg is logged in
+// ProgressLog mode.
+func (p *Progress) phase(name, msg string) {
+	if p == nil {
+		log.Print(msg)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.endPhase()
+	if p.mode == ProgressLog {
+		log.Print(msg)
+	}
+	p.current = name
+	p.started = time.Now()
+	atomic.StoreInt64(&p.items, 0)
+	atomic.StoreInt64(&p.bytes, 0)
+	if p.stop == nil && (p.mode == ProgressBar || p.mode == ProgressJSON) {
+		p.stop = make(chan struct{})
+		p.stopped = make(chan struct{})
+		go p.tick(p.stop, p.stopped)
+	}
+}
+
+// item counts an item handled in the current phase.
+func (p *Progress) item() {
+	if p != nil {
+		atomic.AddInt64(&p.items, 1)
+	}
+}
+
+// readItem counts an item read from the input for the summary.
+func (p *Progress) readItem(v interface{}) {
+	if p != nil {
+		p.read[entityType(v)]++
+	}
+}
+
+func (p *Progress) tick(stop <-chan struct{}, stopped chan<- struct{}) {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			p.mu.Lock()
+			p.render()
+			p.mu.Unlock()
+		case <-stop:
+			close(stopped)
+			return
+		}
+	}
+}
+
+// endPhase records the timing of the current phase and renders its end.
+func (p *Progress) endPhase() {
+	if p.current == "" {
+		return
+	}
+	p.render()
+	if p.mode == ProgressBar {
+		fmt.Fprintln(p.w)
+	}
+	p.timings = append(p.timings, phaseTiming{p.current, time.Since(p.started).Seconds()})
+	p.current = ""
+}
+
+// state is the state of the current phase.
+type state struct {
+	Phase          string   `json:"phase"`
+	Seconds        float64  `json:"seconds"`
+	Items          int64    `json:"items"`
+	ItemsPerSecond float64  `json:"items_per_second"`
+	Bytes          int64    `json:"bytes,omitempty"`
+	TotalBytes     int64    `json:"total_bytes,omitempty"`
+	ETASeconds     *float64 `json:"eta_seconds,omitempty"`
+}
+
+func (p *Progress) state() state {
+	s := state{
+		Phase:   p.current,
+		Seconds: time.Since(p.started).Seconds(),
+		Items:   atomic.LoadInt64(&p.items),
+	}
+	if s.Seconds > 0 {
+		s.ItemsPerSecond = float64(s.Items) / s.Seconds
+	}
+	// only phases reading the input read bytes
+	if s.Bytes = atomic.LoadInt64(&p.bytes); s.Bytes > 0 && p.total > 0 {
+		s.TotalBytes = p.total
+		if s.Bytes < p.total {
+			eta := s.Seconds * float64(p.total-s.Bytes) / float64(s.Bytes)
+			s.ETASeconds = &eta
+		}
+	}
+	return s
+}
+
+func (p *Progress) render() {
+	if p.current == "" {
+		return
+	}
+	s := p.state()
+	switch p.mode {
+	case ProgressJSON:
+		b, _ := json.Marshal(s)
+		fmt.Fprintf(p.w, "%s\n", b)
+	case ProgressBar:
+		line := fmt.Sprintf("%-8s %d items, %.0f/s", s.Phase, s.Items, s.ItemsPerSecond)
+		if s.TotalBytes > 0 {
+			done := float64(s.Bytes) / float64(s.TotalBytes)
+			if done > 1 {
+				done = 1
+			}
+			n := int(done * 20)
+			line = fmt.Sprintf("%-8s [%s%s] %3.0f%% %s/%s, %d items, %.0f/s",
+				s.Phase, strings.Repeat("#", n), strings.Repeat("-", 20-n), 100*done,
+				megabytes(s.Bytes), megabytes(s.TotalBytes), s.Items, s.ItemsPerSecond)
+			if s.ETASeconds != nil {
+				line += ", ETA " + (time.Duration(*s.ETASeconds) * time.Second).String()
+			}
+		}
+		// return to the start of the line and clear it after the text
+		fmt.Fprintf(p.w, "\r%s\x1b[K", line)
+	}
+}
+
+func megabytes(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
+
+// LogWriter returns a writer for log output to w, which in ProgressBar mode
+// clears the bar before each line and draws it again after. ProgressNone
+// discards log output.
+func (p *Progress) LogWriter(w io.Writer) io.Writer {
+	switch {
+	case p == nil:
+		return w
+	case p.mode == ProgressNone:
+		return ioutil.Discard
+	case p.mode == ProgressBar:
+		return &logWriter{p, w}
+	}
+	return w
+}
+
+type logWriter struct {
+	p *Progress
+	w io.Writer
+}
+
+func (lw *logWriter) Write(b []byte) (int, error) {
+	lw.p.mu.Lock()
+	defer lw.p.mu.Unlock()
+	if lw.p.current != "" {
+		fmt.Fprint(lw.w, "\r\x1b[K")
+	}
+	n, err := lw.w.Write(b)
+	lw.p.render()
+	return n, err
+}
+
+// Close ends the current phase and stops reporting.
+func (p *Progress) Close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.endPhase()
+	stop, stopped := p.stop, p.stopped
+	p.stop = nil
+	p.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
+
+// summary closes the Progress of the command and writes counts of items
+// read, matched and collected as related, by type, and the phase timings.
+func (c *Command) summary() error {
+	p := c.Progress
+	if p == nil {
+		return nil
+	}
+	p.Close()
+	if p.mode == ProgressNone {
+		return nil
+	}
+	var matched, collected [3]int64
+	if err := c.countKeys(matchedKeyPrefix, &matched); err != nil {
+		return err
+	}
+	if err := c.countKeys(collectedKeyPrefix, &collected); err != nil {
+		return err
+	}
+	var related [3]int64
+	for t := range related {
+		related[t] = collected[t] - matched[t]
+	}
+	if p.mode == ProgressJSON {
+		b, err := json.Marshal(map[string]interface{}{"summary": map[string]interface{}{
+			"read":    typeCounts(p.read),
+			"matched": typeCounts(matched),
+			"related": typeCounts(related),
+			"phases":  p.timings,
+		}})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", b)
+		return err
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tnodes\tways\trelations\t\n")
+	for _, row := range []struct {
+		name   string
+		counts [3]int64
+	}{{"read", p.read}, {"matched", matched}, {"related", related}} {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t\n", row.name, row.counts[0], row.counts[1], row.counts[2])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	tw = tabwriter.NewWriter(p.w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	var total float64
+	for _, pt := range p.timings {
+		fmt.Fprintf(tw, "%s\t%.2fs\t\n", pt.Phase, pt.Seconds)
+		total += pt.Seconds
+	}
+	fmt.Fprintf(tw, "total\t%.2fs\t\n", total)
+	return tw.Flush()
+}
+
+// typeCounts names counts by type for JSON.
+func typeCounts(counts [3]int64) map[string]int64 {
+	return map[string]int64{
+		"nodes":     counts[osmpbf.NodeType],
+		"ways":      counts[osmpbf.WayType],
+		"relations": counts[osmpbf.RelationType],
+	}
+}
+
+// countKeys counts keys with a prefix by type.
+func (c *Command) countKeys(prefix []byte, counts *[3]int64) error {
+	iter := c.LevelDB.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		k, err := ParseDBKey(iter.Key()[len(prefix):])
+		if err != nil {
+			return err
+		}
+		counts[k.Type]++
+	}
+	return iter.Error()
+}
@@ -0,0 +1,43 @@
+package run_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+)
+
+func TestProgressSummary(t *testing.T) {
+	for _, strategy := range []string{run.StrategyDB, run.StrategyTwoPass} {
+		c := inputCommand(t, strategy, run.ClosureCompleteWays, run.MissingSkip, "building", "", run.FormatJSON)
+		c.Stdout = ioutil.Discard
+		var b bytes.Buffer
+		c.Progress = run.NewProgress(&b, run.ProgressJSON, 0)
+		if err := run.Run(c); err != nil {
+			t.Fatal(err)
+		}
+		c.LevelDB.Close()
+
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		var actual struct {
+			Summary struct {
+				Read, Matched, Related map[string]int64
+			}
+		}
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &actual); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]int64{"nodes": 0, "ways": 1, "relations": 0}
+		if !reflect.DeepEqual(expected, actual.Summary.Matched) {
+			t.Errorf("%s: expected matched %v, actual %v", strategy, expected, actual.Summary.Matched)
+		}
+		expected = map[string]int64{"nodes": 3, "ways": 0, "relations": 0}
+		if !reflect.DeepEqual(expected, actual.Summary.Related) {
+			t.Errorf("%s: expected related %v, actual %v", strategy, expected, actual.Summary.Related)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package run
+
+import (
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+var insideKeyPrefix = []byte("inside")
+
+// regionMatch checks if an item is inside the region of the command. A node
+// is inside if its position is, a way if any of its nodes is and a relation
+// if any of its members is. Items found inside are marked so that parents
+// can be checked, which needs their members checked first: ways and
+// relations are matched by matchInside once all of the input is stored.
+func (c *Command) regionMatch(v interface{}) (bool, error) {
+	if c.Region == nil {
+		return true, nil
+	}
+	var (
+		key    []byte
+		inside bool
+		err    error
+	)
+	switch v := v.(type) {
+	case *osmpbf.Node:
+		key = (&DBKey{Type: osmpbf.NodeType, ID: v.ID}).Bytes()
+		inside = c.Region.Contains(v.Lat, v.Lon)
+	case *osmpbf.Way:
+		key = (&DBKey{Type: osmpbf.WayType, ID: v.ID}).Bytes()
+		for _, id := range v.NodeIDs {
+			if inside, err = c.isInside(osmpbf.NodeType, id); inside || err != nil {
+				break
+			}
+		}
+	case *osmpbf.Relation:
+		key = (&DBKey{Type: osmpbf.RelationType, ID: v.ID}).Bytes()
+		for _, m := range v.Members {
+			if inside, err = c.isInside(m.Type, m.ID); inside || err != nil {
+				break
+			}
+		}
+	}
+	if !inside || err != nil {
+		return false, err
+	}
+	return true, c.dbPut(prefixed(insideKeyPrefix, key), nil)
+}
+
+func (c *Command) isInside(t osmpbf.MemberType, id int64) (bool, error) {
+	key := (&DBKey{Type: t, ID: id}).Bytes()
+	return c.LevelDB.Has(prefixed(insideKeyPrefix, key), nil)
+}
+
+// matchInside matches stored ways and relations inside the region, after
+// PutData has marked the nodes inside.
+func (c *Command) matchInside() error {
+	if err := c.rematchType(osmpbf.WayType); err != nil {
+		return err
+	}
+	if err := c.markRelationsInside(); err != nil {
+		return err
+	}
+	return c.rematchType(osmpbf.RelationType)
+}
+
+// rematchType collects stored items of type t which are inside the region
+// and match tags, marking them as matched.
+func (c *Command) rematchType(t osmpbf.MemberType) error {
+	iter := c.LevelDB.NewIterator(util.BytesPrefix(typeKeyPrefix(t)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		c.Progress.item()
+		e, err := decodeEntity(iter.Key(), iter.Value())
+		if err != nil {
+			return err
+		}
+		inside, err := c.regionMatch(e.v)
+		if err != nil {
+			return err
+		}
+		if !inside || !c.TagsMatch(e.v) {
+			continue
+		}
+		if _, _, err := c.collectKey(e.key.Type, e.key.ID); err != nil {
+			return err
+		}
+		if err := c.dbPut(prefixed(matchedKeyPrefix, iter.Key()), nil); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// markRelationsInside marks stored relations inside the region whose
+// members are. Relations may have member relations stored after them, so
+// relations are read again until no more are found inside.
+func (c *Command) markRelationsInside() error {
+	if c.Region == nil {
+		return nil
+	}
+	for {
+		found, err := c.markRelationsInsideOnce()
+		if !found || err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Command) markRelationsInsideOnce() (found bool, err error) {
+	iter := c.LevelDB.NewIterator(util.BytesPrefix(typeKeyPrefix(osmpbf.RelationType)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		marked, err := c.LevelDB.Has(prefixed(insideKeyPrefix, iter.Key()), nil)
+		if err != nil {
+			return false, err
+		}
+		if marked {
+			continue
+		}
+		e, err := decodeEntity(iter.Key(), iter.Value())
+		if err != nil {
+			return false, err
+		}
+		inside, err := c.regionMatch(e.v)
+		if err != nil {
+			return false, err
+		}
+		found = found || inside
+	}
+	return found, iter.Error()
+}
@@ -0,0 +1,67 @@
+package run_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/geo"
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/ambiweb/osm-pbf-filter/tags"
+	"github.com/qedus/osmpbf"
+)
+
+// regionInput has members read after their parents, as with several inputs.
+var regionInput = []interface{}{
+	&osmpbf.Way{ID: 20, Tags: map[string]string{"highway": "primary"}, NodeIDs: []int64{7, 8}},
+	&osmpbf.Node{ID: 7, Lat: 1, Lon: 1, Tags: map[string]string{}},
+	&osmpbf.Node{ID: 8, Lat: 5, Lon: 5, Tags: map[string]string{}},
+	&osmpbf.Way{ID: 21, Tags: map[string]string{"highway": "primary"}, NodeIDs: []int64{8}},
+	&osmpbf.Relation{ID: 200, Tags: map[string]string{"type": "site"}, Members: []osmpbf.Member{
+		{ID: 201, Type: osmpbf.RelationType},
+	}},
+	&osmpbf.Relation{ID: 201, Tags: map[string]string{"type": "route"}, Members: []osmpbf.Member{
+		{ID: 20, Type: osmpbf.WayType},
+	}},
+	&osmpbf.Relation{ID: 202, Tags: map[string]string{"type": "site"}, Members: []osmpbf.Member{
+		{ID: 21, Type: osmpbf.WayType},
+	}},
+}
+
+var regionTests = []struct {
+	expr     string
+	expected string
+}{
+	{"highway", "n7 n8 w20"},
+	{"type=site", "n7 n8 w20 r200 r201"},
+}
+
+func TestRegion(t *testing.T) {
+	bbox, err := geo.ParseBBox("0,0,2,2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range regionTests {
+		x, err := tags.ParseExpr(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := newCommand(t)
+		c.TagsMatcher = x
+		c.Region = bbox
+		c.Format = run.FormatJSON
+		// the second run matches the items stored by the first one
+		for _, pass := range []string{"put", "rematch"} {
+			d := sliceDecoder(regionInput)
+			c.PBFDecoder = &d
+			var b bytes.Buffer
+			c.Stdout = &b
+			if err := run.Run(c); err != nil {
+				t.Fatal(err)
+			}
+			if actual := refs(t, b.String()); actual != tt.expected {
+				t.Errorf("%s %s: expected %s, actual %s", tt.expr, pass, tt.expected, actual)
+			}
+		}
+		c.LevelDB.Close()
+	}
+}
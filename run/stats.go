@@ -0,0 +1,123 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/qedus/osmpbf"
+)
+
+// Stats counts entities, their tags and versions, by type.
+type Stats struct {
+	Counts     [3]int64
+	Tags       [3]int64
+	FirstVer   [3]int64 // entities in their first version
+	MaxVersion [3]int32
+	Keys       map[string]int64 // entities with a tag, by key
+
+	versions [3]int64
+}
+
+// ReadStats counts the entities of the input.
+func ReadStats(dec Decoder) (*Stats, error) {
+	s := &Stats{Keys: make(map[string]int64)}
+	err := traverse(dec.Decode, func(v interface{}) error {
+		s.Add(v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add counts a Node, Way or Relation.
+func (s *Stats) Add(v interface{}) {
+	var tags map[string]string
+	switch v := v.(type) {
+	case *osmpbf.Node:
+		tags = v.Tags
+	case *osmpbf.Way:
+		tags = v.Tags
+	case *osmpbf.Relation:
+		tags = v.Tags
+	default:
+		return
+	}
+	t := entityType(v)
+	version := infoOf(v).Version
+	s.Counts[t]++
+	s.Tags[t] += int64(len(tags))
+	s.versions[t] += int64(version)
+	if version <= 1 {
+		s.FirstVer[t]++
+	}
+	if version > s.MaxVersion[t] {
+		s.MaxVersion[t] = version
+	}
+	for k := range tags {
+		s.Keys[k]++
+	}
+}
+
+// MeanVersion returns the mean version of entities of type t.
+func (s *Stats) MeanVersion(t osmpbf.MemberType) float64 {
+	if s.Counts[t] == 0 {
+		return 0
+	}
+	return float64(s.versions[t]) / float64(s.Counts[t])
+}
+
+// WriteTo writes the stats as a table, with the top keys by use.
+func (s *Stats) WriteTo(w io.Writer, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\tnodes\tways\trelations\t\n")
+	row := func(name string, f func(t osmpbf.MemberType) string) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", name,
+			f(osmpbf.NodeType), f(osmpbf.WayType), f(osmpbf.RelationType))
+	}
+	row("count", func(t osmpbf.MemberType) string { return fmt.Sprint(s.Counts[t]) })
+	row("tags", func(t osmpbf.MemberType) string { return fmt.Sprint(s.Tags[t]) })
+	row("version 1", func(t osmpbf.MemberType) string { return fmt.Sprint(s.FirstVer[t]) })
+	row("mean version", func(t osmpbf.MemberType) string { return fmt.Sprintf("%.2f", s.MeanVersion(t)) })
+	row("max version", func(t osmpbf.MemberType) string { return fmt.Sprint(s.MaxVersion[t]) })
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	keys := make(keyCounts, 0, len(s.Keys))
+	for k, n := range s.Keys {
+		keys = append(keys, keyCount{k, n})
+	}
+	sort.Sort(keys)
+	if top > len(keys) {
+		top = len(keys)
+	}
+	if _, err := fmt.Fprintf(w, "\n%d keys, top %d:\n", len(keys), top); err != nil {
+		return err
+	}
+	tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, kc := range keys[:top] {
+		fmt.Fprintf(tw, "  %s\t%d\n", kc.key, kc.n)
+	}
+	return tw.Flush()
+}
+
+type keyCount struct {
+	key string
+	n   int64
+}
+
+// keyCounts sort by count, most used first, then by key.
+type keyCounts []keyCount
+
+func (s keyCounts) Len() int      { return len(s) }
+func (s keyCounts) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s keyCounts) Less(i, j int) bool {
+	if s[i].n != s[j].n {
+		return s[i].n > s[j].n
+	}
+	return s[i].key < s[j].key
+}
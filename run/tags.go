@@ -1,15 +1,37 @@
 package run
 
 import (
+	"github.com/ambiweb/osm-pbf-filter/tags"
 	"github.com/qedus/osmpbf"
 )
 
 func (c *Command) tagsMatch(v interface{}) bool {
+	if c.IDs != nil && c.IDs.Has(entityType(v), entityID(v)) {
+		return true
+	}
+	if c.TagsMatcher == nil {
+		// selecting by ID alone
+		return c.IDs == nil
+	}
+	var e tags.Element
 	switch v := v.(type) {
 	case *osmpbf.Node:
+		e = tags.Element{Type: tags.Node, Tags: v.Tags, Info: info(&v.Info)}
 	case *osmpbf.Way:
+		e = tags.Element{Type: tags.Way, Tags: v.Tags, Info: info(&v.Info)}
 	case *osmpbf.Relation:
-		return c.TagsMatcher.Match(v.Tags)
+		e = tags.Element{Type: tags.Relation, Tags: v.Tags, Info: info(&v.Info)}
+	default:
+		return false
+	}
+	return c.TagsMatcher.Eval(&e)
+}
+
+// info returns the metadata of an item, nil for inputs without it, where
+// the version is 0.
+func info(i *osmpbf.Info) *osmpbf.Info {
+	if i.Version == 0 {
+		return nil
 	}
-	return false
+	return i
 }
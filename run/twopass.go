@@ -0,0 +1,292 @@
+package run
+
+import (
+	"errors"
+	"io"
+
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// Strategies tell how items are selected.
+const (
+	// StrategyDB stores every item of the input in levelDB and collects
+	// related items there.
+	StrategyDB = "db"
+	// StrategyTwoPass scans the input for IDs of matching and related items
+	// first, keeping them in memory, and reads it again to keep only those.
+	// It stores only the items it collects, which pays off for extracts much
+	// smaller than the input, but it can not filter by region. Relations
+	// selected by relations read after them and ClosureSmart take more
+	// passes.
+	StrategyTwoPass = "twopass"
+)
+
+// selection holds what the two-pass strategy has selected so far. It keeps
+// IDs only, so members of relations read before a relation selecting them
+// need the input to be read again.
+type selection struct {
+	nodes, ways, relations idSet
+	// pending are ways whose nodes are not selected yet
+	pending idSet
+	// positions are nodes not selected but needed for geometries
+	positions idSet
+	// queue are selected relations whose members are not selected yet
+	queue idSet
+	// known are the relations of the input
+	known idSet
+	// areas counts multipolygon and boundary relations of the input
+	areas int
+}
+
+// runTwoPass selects items with StrategyTwoPass and collects them in
+// c.LevelDB, an in-memory one if it is nil, for the output.
+func (c *Command) runTwoPass() error {
+	if c.Region != nil {
+		return errors.New("strategy twopass does not support region filters")
+	}
+	if c.Reopen == nil {
+		return errors.New("strategy twopass needs to read the input again")
+	}
+	if c.LevelDB == nil {
+		db, err := leveldb.Open(storage.NewMemStorage(), nil)
+		if err != nil {
+			return err
+		}
+		c.LevelDB = db
+	}
+	s := &selection{
+		nodes:     make(idSet),
+		ways:      make(idSet),
+		relations: make(idSet),
+		pending:   make(idSet),
+		positions: make(idSet),
+		queue:     make(idSet),
+		known:     make(idSet),
+	}
+	c.Progress.phase("scan", "Start scanning PBF for matching items")
+	if err := c.TraverseData(s.scan(c)); err != nil {
+		return err
+	}
+	if err := c.selectRelations(s); err != nil {
+		return err
+	}
+	if c.Closure == ClosureSmart && s.areas > 0 {
+		if err := c.selectMultipolygons(s); err != nil {
+			return err
+		}
+	}
+	if len(s.pending) > 0 {
+		c.Progress.phase("nodes", "Start scanning PBF for nodes of related ways")
+		nodes := s.nodes
+		if c.Closure == ClosureSimple {
+			nodes = s.positions
+		}
+		err := c.traverseAgain(func(v interface{}) error {
+			if w, ok := v.(*osmpbf.Way); ok && s.pending.has(w.ID) {
+				for _, id := range w.NodeIDs {
+					nodes.add(id)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	c.Progress.phase("collect", "Start collecting selected items from PBF")
+	err := c.traverseAgain(func(v interface{}) error {
+		var set idSet
+		switch v.(type) {
+		case *osmpbf.Node:
+			set = s.nodes
+		case *osmpbf.Way:
+			set = s.ways
+		case *osmpbf.Relation:
+			set = s.relations
+		}
+		fn := c.Collect
+		if c.TagsMatch(v) {
+			fn = c.collectMatched
+		}
+		if !set.has(entityID(v)) {
+			if _, ok := v.(*osmpbf.Node); !ok || !s.positions.has(entityID(v)) {
+				return nil
+			}
+			fn = c.Put
+		}
+		if c.Dedupe {
+			stale, err := c.stale(v)
+			if stale || err != nil {
+				return err
+			}
+		}
+		return fn(v)
+	})
+	if err != nil {
+		return err
+	}
+	// relations missing are known since the scan, nodes and ways only now
+	for _, t := range []osmpbf.MemberType{osmpbf.NodeType, osmpbf.WayType} {
+		set := s.nodes
+		if t == osmpbf.WayType {
+			set = s.ways
+		}
+		for _, id := range set.sorted() {
+			key := (&DBKey{Type: t, ID: id}).Bytes()
+			ok, err := c.LevelDB.Has(prefixed(collectedKeyPrefix, key), nil)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				if err := c.missingMember(t, id); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// scan selects matching items, nodes of matching ways and members of
+// matching relations and of selected relations read after those selecting
+// them.
+func (s *selection) scan(c *Command) TraverseDataFunc {
+	return func(v interface{}) error {
+		c.Progress.readItem(v)
+		switch v := v.(type) {
+		case *osmpbf.Node:
+			if c.TagsMatch(v) {
+				s.nodes.add(v.ID)
+			}
+		case *osmpbf.Way:
+			if c.TagsMatch(v) {
+				s.ways.add(v.ID)
+				for _, id := range v.NodeIDs {
+					s.nodes.add(id)
+				}
+			}
+		case *osmpbf.Relation:
+			s.known.add(v.ID)
+			if t := v.Tags["type"]; t == "multipolygon" || t == "boundary" {
+				s.areas++
+			}
+			if s.queue.has(v.ID) || c.TagsMatch(v) && !s.relations.has(v.ID) {
+				s.relations.add(v.ID)
+				c.selectMembers(s, v)
+			}
+		}
+		return nil
+	}
+}
+
+// selectMembers selects members of a relation, the way collectMembers does.
+// Member relations are queued to select their members in turn.
+func (c *Command) selectMembers(s *selection, r *osmpbf.Relation) {
+	delete(s.queue, r.ID)
+	for _, m := range r.Members {
+		switch m.Type {
+		case osmpbf.NodeType:
+			s.nodes.add(m.ID)
+		case osmpbf.WayType:
+			if s.ways.has(m.ID) {
+				continue
+			}
+			s.ways.add(m.ID)
+			// GeoJSON output needs positions of way nodes in any case
+			if c.Closure != ClosureSimple || c.Format == FormatGeoJSON {
+				s.pending.add(m.ID)
+			}
+		case osmpbf.RelationType:
+			if s.relations.has(m.ID) {
+				continue
+			}
+			s.relations.add(m.ID)
+			s.queue.add(m.ID)
+		}
+	}
+}
+
+// selectRelations reads the input again for members of queued relations
+// until none is left. Relations are queued when a relation read after them
+// selects them, so this takes a pass per level of such super-relations.
+func (c *Command) selectRelations(s *selection) error {
+	for {
+		for _, id := range s.queue.sorted() {
+			if s.known.has(id) {
+				continue
+			}
+			delete(s.queue, id)
+			if err := c.missingMember(osmpbf.RelationType, id); err != nil {
+				return err
+			}
+		}
+		if len(s.queue) == 0 {
+			return nil
+		}
+		c.Progress.phase("relations", "Start scanning PBF for members of related relations")
+		err := c.traverseAgain(func(v interface{}) error {
+			if r, ok := v.(*osmpbf.Relation); ok && s.queue.has(r.ID) {
+				c.selectMembers(s, r)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// selectMultipolygons reads the input again to select multipolygon and
+// boundary relations with selected member ways, the way
+// collectMultipolygons does.
+func (c *Command) selectMultipolygons(s *selection) error {
+	c.Progress.phase("areas", "Start scanning PBF for multipolygons of selected ways")
+	err := c.traverseAgain(func(v interface{}) error {
+		r, ok := v.(*osmpbf.Relation)
+		if !ok || s.relations.has(r.ID) {
+			return nil
+		}
+		if t := r.Tags["type"]; t != "multipolygon" && t != "boundary" {
+			return nil
+		}
+		for _, m := range r.Members {
+			if m.Type == osmpbf.WayType && s.ways.has(m.ID) {
+				s.relations.add(r.ID)
+				c.selectMembers(s, r)
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.selectRelations(s)
+}
+
+// traverseAgain reads the input once more, from a decoder opened by c.Reopen.
+func (c *Command) traverseAgain(fn TraverseDataFunc) error {
+	dec, err := c.Reopen()
+	if err != nil {
+		return err
+	}
+	if cl, ok := dec.(io.Closer); ok {
+		defer cl.Close()
+	}
+	return traverse(dec.Decode, c.counted(fn))
+}
+
+// entityID returns the ID of a Node, Way or Relation.
+func entityID(v interface{}) int64 {
+	switch v := v.(type) {
+	case *osmpbf.Node:
+		return v.ID
+	case *osmpbf.Way:
+		return v.ID
+	case *osmpbf.Relation:
+		return v.ID
+	}
+	return 0
+}
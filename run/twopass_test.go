@@ -0,0 +1,25 @@
+package run_test
+
+import (
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+)
+
+func TestTwoPass(t *testing.T) {
+	for _, closure := range []string{run.ClosureSimple, run.ClosureCompleteWays, run.ClosureSmart} {
+		for _, expr := range []string{"amenity", "building", "type=route", "type=route_master", "type=site"} {
+			expected, err := selectInput(t, run.StrategyDB, closure, run.MissingSkip, expr, "", run.FormatJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := selectInput(t, run.StrategyTwoPass, closure, run.MissingSkip, expr, "", run.FormatJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected != actual {
+				t.Errorf("%s %s: expected %s, actual %s", closure, expr, expected, actual)
+			}
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package run
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/ambiweb/osm-pbf-filter/osmxml"
+	"github.com/qedus/osmpbf"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Update applies the changes of the command to the input stored in levelDB
+// by an earlier run, matches changed items and their parents again and
+// outputs the updated extract or, with Diff, its changes as OsmChange. If
+// nothing is stored yet, the input is stored first. The filters should be the
+// ones of the run which stored the input, as matched items are taken over.
+// levelDB is marked as updated, so that Run refuses to reuse it.
+func Update(c *Command) error {
+	if c.Changes == nil {
+		return errors.New("no changes to apply")
+	}
+	phase, _, err := c.storedState()
+	if err != nil {
+		return err
+	}
+	if phase != phasePut {
+		if err := c.store(); err != nil {
+			return err
+		}
+		// members missing before the changes are counted again below
+		c.missing = missingSummary{}
+	}
+	snap, err := c.LevelDB.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	c.Progress.phase("apply", "Start applying changes")
+	changed, err := c.applyChanges()
+	if err != nil {
+		return err
+	}
+	if err := c.dbPut(updatedKey, nil); err != nil {
+		return err
+	}
+	log.Printf("Applied changes of %d items", len(changed))
+	c.Progress.phase("rematch", "Start matching changed items")
+	if err := c.uncollect(); err != nil {
+		return err
+	}
+	if err := c.rematchChanged(changed); err != nil {
+		return err
+	}
+	c.Progress.phase("collect", "Start collecting related items")
+	if err := c.collectAllMatched(); err != nil {
+		return err
+	}
+	if err := c.CollectRelated(); err != nil {
+		return err
+	}
+	c.missing.log()
+	if c.Diff {
+		c.Progress.phase("output", "Preparing to output changes")
+		err = c.outputDiff(snap)
+	} else {
+		c.Progress.phase("output", fmt.Sprintf("Preparing to output %s", c.Format))
+		err = c.Output()
+	}
+	if err != nil {
+		return err
+	}
+	return c.summary()
+}
+
+// applyChanges stores created and modified items and deletes deleted ones,
+// with their markers. It returns the keys of the items changed.
+func (c *Command) applyChanges() (map[DBKey]bool, error) {
+	changed := make(map[DBKey]bool)
+	for {
+		action, v, err := c.Changes.DecodeChange()
+		if err == io.EOF {
+			return changed, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Progress.item()
+		key, ok, err := c.applyChange(action, v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			changed[key] = true
+		}
+	}
+}
+
+// applyChange applies a change unless the stored item has the same or a newer
+// version, e.g. as the change was applied before.
+func (c *Command) applyChange(action osmxml.Action, v interface{}) (DBKey, bool, error) {
+	key, value, err := KeyValue(v)
+	if err != nil {
+		return DBKey{}, false, err
+	}
+	k, err := ParseDBKey(key)
+	if err != nil {
+		return k, false, err
+	}
+	switch action {
+	case osmxml.Create, osmxml.Modify, osmxml.Delete:
+	default:
+		return k, false, fmt.Errorf("change of %s is outside of create, modify and delete", typeRef(k.Type, k.ID))
+	}
+	stored, err := c.lookup(k.Type, k.ID)
+	if err == nil {
+		if version := infoOf(v).Version; version != 0 && infoOf(stored).Version >= version {
+			return k, false, nil
+		}
+	} else if err != leveldb.ErrNotFound {
+		return k, false, err
+	}
+	for _, prefix := range [][]byte{nil, collectedKeyPrefix, insideKeyPrefix, matchedKeyPrefix} {
+		if err := c.dbDelete(prefixed(prefix, key)); err != nil {
+			return k, false, err
+		}
+	}
+	if action == osmxml.Delete {
+		return k, true, nil
+	}
+	return k, true, c.dbPut(key, value)
+}
+
+// rematchChanged matches changed items again. With a region, parents of
+// changed items are matched again too, as whether they are inside depends on
+// their members; finding them takes a scan of all stored ways. Relations may
+// be inside through member relations with higher IDs, so all of them are
+// matched again.
+func (c *Command) rematchChanged(changed map[DBKey]bool) error {
+	if c.Region == nil {
+		for k := range changed {
+			v, err := c.lookup(k.Type, k.ID)
+			if err == leveldb.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := c.rematchItem(k.Bytes(), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for k := range changed {
+		if k.Type != osmpbf.NodeType {
+			continue
+		}
+		v, err := c.lookup(k.Type, k.ID)
+		if err == leveldb.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.rematchItem(k.Bytes(), v); err != nil {
+			return err
+		}
+	}
+	if err := c.rematchParents(osmpbf.WayType, changed); err != nil {
+		return err
+	}
+	for _, prefix := range [][]byte{insideKeyPrefix, matchedKeyPrefix} {
+		if err := c.deletePrefix(prefixed(prefix, typeKeyPrefix(osmpbf.RelationType))); err != nil {
+			return err
+		}
+	}
+	if err := c.markRelationsInside(); err != nil {
+		return err
+	}
+	return c.rematchType(osmpbf.RelationType)
+}
+
+// rematchParents matches stored items of type t again which are changed or
+// have changed members. Those are added to changed, for their parents.
+func (c *Command) rematchParents(t osmpbf.MemberType, changed map[DBKey]bool) error {
+	iter := c.LevelDB.NewIterator(util.BytesPrefix(typeKeyPrefix(t)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		e, err := decodeEntity(iter.Key(), iter.Value())
+		if err != nil {
+			return err
+		}
+		affected := changed[e.key]
+		switch v := e.v.(type) {
+		case *osmpbf.Way:
+			for _, id := range v.NodeIDs {
+				if affected {
+					break
+				}
+				affected = changed[DBKey{Type: osmpbf.NodeType, ID: id}]
+			}
+		case *osmpbf.Relation:
+			for _, m := range v.Members {
+				if affected {
+					break
+				}
+				affected = changed[DBKey{Type: m.Type, ID: m.ID}]
+			}
+		}
+		if !affected {
+			continue
+		}
+		changed[e.key] = true
+		if err := c.rematchItem(iter.Key(), e.v); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// rematchItem renews the inside and matched markers of a stored item.
+func (c *Command) rematchItem(key []byte, v interface{}) error {
+	for _, prefix := range [][]byte{insideKeyPrefix, matchedKeyPrefix} {
+		if err := c.dbDelete(prefixed(prefix, key)); err != nil {
+			return err
+		}
+	}
+	inside, err := c.regionMatch(v)
+	if err != nil || !inside || !c.TagsMatch(v) {
+		return err
+	}
+	return c.dbPut(prefixed(matchedKeyPrefix, key), nil)
+}
+
+// collectAllMatched collects items marked as matched.
+func (c *Command) collectAllMatched() error {
+	iter := c.LevelDB.NewIterator(util.BytesPrefix(matchedKeyPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		k, err := ParseDBKey(iter.Key()[len(matchedKeyPrefix):])
+		if err != nil {
+			return err
+		}
+		if _, _, err := c.collectKey(k.Type, k.ID); err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
+// outputDiff outputs the changes of the collected items since the snapshot
+// as OsmChange. Items collected before only are deleted from the extract.
+// Deletions come last, in reverse order, so that no item is deleted before
+// its parents.
+func (c *Command) outputDiff(snap *leveldb.Snapshot) error {
+	enc := osmxml.NewChangeEncoder(c.Stdout)
+	prefix := util.BytesPrefix(collectedKeyPrefix)
+	before := snap.NewIterator(prefix, nil)
+	defer before.Release()
+	after := c.LevelDB.NewIterator(prefix, nil)
+	defer after.Release()
+
+	var deleted []interface{}
+	encode := func(action osmxml.Action, iter iterator.Iterator) error {
+		e, err := decodeEntity(iter.Key()[len(collectedKeyPrefix):], iter.Value())
+		if err != nil {
+			return err
+		}
+		if action == osmxml.Delete {
+			deleted = append(deleted, e.v)
+			return nil
+		}
+		return enc.Encode(action, e.v)
+	}
+	okBefore, okAfter := before.Next(), after.Next()
+	for okBefore || okAfter {
+		cmp := 0
+		switch {
+		case !okBefore:
+			cmp = 1
+		case !okAfter:
+			cmp = -1
+		default:
+			cmp = bytes.Compare(before.Key(), after.Key())
+		}
+		var err error
+		switch {
+		case cmp < 0:
+			err = encode(osmxml.Delete, before)
+			okBefore = before.Next()
+		case cmp > 0:
+			err = encode(osmxml.Create, after)
+			okAfter = after.Next()
+		default:
+			if !bytes.Equal(before.Value(), after.Value()) {
+				err = encode(osmxml.Modify, after)
+			}
+			okBefore, okAfter = before.Next(), after.Next()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := before.Error(); err != nil {
+		return err
+	}
+	if err := after.Error(); err != nil {
+		return err
+	}
+	for i := len(deleted) - 1; i >= 0; i-- {
+		if err := enc.Encode(osmxml.Delete, deleted[i]); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
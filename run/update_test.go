@@ -0,0 +1,127 @@
+package run_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ambiweb/osm-pbf-filter/geo"
+	"github.com/ambiweb/osm-pbf-filter/osmxml"
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/ambiweb/osm-pbf-filter/tags"
+	"github.com/qedus/osmpbf"
+)
+
+type change struct {
+	action osmxml.Action
+	v      interface{}
+}
+
+// sliceChangeDecoder decodes changes from a slice.
+type sliceChangeDecoder []change
+
+func (d *sliceChangeDecoder) DecodeChange() (osmxml.Action, interface{}, error) {
+	if len(*d) == 0 {
+		return "", nil, io.EOF
+	}
+	ch := (*d)[0]
+	*d = (*d)[1:]
+	return ch.action, ch.v, nil
+}
+
+func TestUpdate(t *testing.T) {
+	x, err := tags.ParseExpr("amenity or type=route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newCommand(t)
+	defer c.LevelDB.Close()
+	d := sliceDecoder(input)
+	c.PBFDecoder = &d
+	c.TagsMatcher = x
+	c.Format = run.FormatNDJSON
+	c.Stdout = &bytes.Buffer{}
+	if err := run.Run(c); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := sliceChangeDecoder{
+		{osmxml.Create, &osmpbf.Node{ID: 5, Tags: map[string]string{"amenity": "bench"}, Info: osmpbf.Info{Version: 1, Visible: true}}},
+		{osmxml.Modify, &osmpbf.Node{ID: 3, Tags: map[string]string{}, Info: osmpbf.Info{Version: 2, Visible: true}}},
+		{osmxml.Delete, &osmpbf.Relation{ID: 102, Info: osmpbf.Info{Version: 2, Visible: true}}},
+	}
+	c.Changes = &changes
+	c.Diff = true
+	var b bytes.Buffer
+	c.Stdout = &b
+	if err := run.Update(c); err != nil {
+		t.Fatal(err)
+	}
+	// node 3 stays as a node of way 11 of route 101
+	for _, s := range []string{
+		"<create>\n    <node id=\"5\"",
+		"<modify>\n    <node id=\"3\" version=\"2\"",
+		"<delete>\n    <relation id=\"102\"",
+	} {
+		if !strings.Contains(b.String(), s) {
+			t.Errorf("Expected %q in %s", s, b.String())
+		}
+	}
+
+	// the input files do not have the changes
+	d = sliceDecoder(input)
+	c.PBFDecoder = &d
+	if err := run.Run(c); err == nil || !strings.Contains(err.Error(), "update") {
+		t.Errorf("Expected error naming update, actual %v", err)
+	}
+}
+
+func TestUpdateRegion(t *testing.T) {
+	bbox, err := geo.ParseBBox("0,0,2,2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := tags.ParseExpr("type=site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a site inside through a route with a higher ID
+	nested := []interface{}{
+		&osmpbf.Node{ID: 1, Lat: 1, Lon: 1, Tags: map[string]string{}, Info: osmpbf.Info{Version: 1}},
+		&osmpbf.Way{ID: 10, Tags: map[string]string{}, NodeIDs: []int64{1}},
+		&osmpbf.Relation{ID: 1, Tags: map[string]string{"type": "site"}, Members: []osmpbf.Member{
+			{ID: 2, Type: osmpbf.RelationType},
+		}},
+		&osmpbf.Relation{ID: 2, Tags: map[string]string{"type": "route"}, Members: []osmpbf.Member{
+			{ID: 10, Type: osmpbf.WayType},
+		}},
+	}
+	c := newCommand(t)
+	defer c.LevelDB.Close()
+	d := sliceDecoder(nested)
+	c.PBFDecoder = &d
+	c.TagsMatcher = x
+	c.Region = bbox
+	c.Format = run.FormatNDJSON
+	var b bytes.Buffer
+	c.Stdout = &b
+	if err := run.Run(c); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"type":"relation","id":1,`) {
+		t.Fatalf("Expected relation 1 in %s", b.String())
+	}
+
+	changes := sliceChangeDecoder{
+		{osmxml.Modify, &osmpbf.Node{ID: 1, Lat: 5, Lon: 5, Tags: map[string]string{}, Info: osmpbf.Info{Version: 2, Visible: true}}},
+	}
+	c.Changes = &changes
+	b.Reset()
+	if err := run.Update(c); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Expected no items, actual %s", b.String())
+	}
+}
@@ -0,0 +1,71 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Writer writes collected items in an output format. Write gets a
+// *osmpbf.Node, *osmpbf.Way or *osmpbf.Relation for each item, in key
+// order; Close finishes the output. Both return write errors.
+type Writer interface {
+	Write(v interface{}) error
+	Close() error
+}
+
+// NewWriter returns a writer of an output format to c.Stdout.
+func (c *Command) NewWriter(format string) (Writer, error) {
+	switch format {
+	case FormatJSON, "":
+		return NewJSONWriter(c.Stdout), nil
+	case FormatNDJSON:
+		return NewNDJSONWriter(c.Stdout), nil
+	case FormatCSV:
+		return NewCSVWriter(c.Stdout, c.CSVColumns), nil
+	case FormatPBF:
+		return newPBFWriter(c.Stdout, c.header), nil
+	case FormatOSM:
+		return newOSMXMLWriter(c.Stdout, c.header), nil
+	case FormatGeoJSON:
+		return c.newGeoJSONWriter(), nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// jsonWriter writes items as a JSON array of osmpbf structs.
+type jsonWriter struct {
+	w io.Writer
+	n int
+}
+
+// NewJSONWriter returns a writer of a JSON array.
+func NewJSONWriter(w io.Writer) Writer {
+	return &jsonWriter{w: w}
+}
+
+func (jw *jsonWriter) Write(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sep := ","
+	if jw.n == 0 {
+		sep = "["
+	}
+	jw.n++
+	if _, err := io.WriteString(jw.w, sep); err != nil {
+		return err
+	}
+	_, err = jw.w.Write(b)
+	return err
+}
+
+func (jw *jsonWriter) Close() error {
+	end := "]"
+	if jw.n == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(jw.w, end)
+	return err
+}
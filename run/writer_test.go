@@ -0,0 +1,87 @@
+package run_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ambiweb/osm-pbf-filter/run"
+	"github.com/qedus/osmpbf"
+)
+
+var items = []interface{}{
+	&osmpbf.Node{ID: 1, Lat: 52.51, Lon: 1e-9 * float64(100*116000000), Tags: map[string]string{"name": "A, B", "amenity": "cafe"},
+		Info: osmpbf.Info{Version: 2, Timestamp: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Uid: 9, User: "u", Visible: true}},
+	&osmpbf.Way{ID: 10, Tags: map[string]string{}, NodeIDs: []int64{1, 2}},
+	&osmpbf.Relation{ID: 100, Tags: map[string]string{"type": "route"}, Members: []osmpbf.Member{
+		{ID: 10, Type: osmpbf.WayType, Role: "forward"},
+	}},
+}
+
+var writerTests = []struct {
+	w        func(*bytes.Buffer) run.Writer
+	expected string
+}{
+	{
+		func(b *bytes.Buffer) run.Writer { return run.NewNDJSONWriter(b) },
+		`{"type":"node","id":1,"tags":{"amenity":"cafe","name":"A, B"},"lat":52.51,"lon":11.6,"nodes":null,"members":null,"info":{"version":2,"timestamp":"2026-01-02T00:00:00Z","changeset":0,"uid":9,"user":"u","visible":true}}
+{"type":"way","id":10,"tags":{},"lat":null,"lon":null,"nodes":[1,2],"members":null,"info":{"version":0,"timestamp":"","changeset":0,"uid":0,"user":"","visible":false}}
+{"type":"relation","id":100,"tags":{"type":"route"},"lat":null,"lon":null,"nodes":null,"members":[{"type":"way","ref":10,"role":"forward"}],"info":{"version":0,"timestamp":"","changeset":0,"uid":0,"user":"","visible":false}}
+`,
+	},
+	{
+		func(b *bytes.Buffer) run.Writer { return run.NewCSVWriter(b, []string{"name", "cuisine"}) },
+		`id,lat,lon,name,cuisine
+1,52.5100000,11.6000000,"A, B",
+`,
+	},
+	{
+		func(b *bytes.Buffer) run.Writer { return run.NewJSONWriter(b) },
+		`[{"ID":1,"Lat":52.51,"Lon":11.600000000000001,"Tags":{"amenity":"cafe","name":"A, B"},"Info":{"Version":2,"Timestamp":"2026-01-02T00:00:00Z","Changeset":0,"Uid":9,"User":"u","Visible":true}},` +
+			`{"ID":10,"Tags":{},"NodeIDs":[1,2],"Info":{"Version":0,"Timestamp":"0001-01-01T00:00:00Z","Changeset":0,"Uid":0,"User":"","Visible":false}},` +
+			`{"ID":100,"Tags":{"type":"route"},"Members":[{"ID":10,"Type":1,"Role":"forward"}],"Info":{"Version":0,"Timestamp":"0001-01-01T00:00:00Z","Changeset":0,"Uid":0,"User":"","Visible":false}}]`,
+	},
+}
+
+func TestWriters(t *testing.T) {
+	for _, tt := range writerTests {
+		var b bytes.Buffer
+		w := tt.w(&b)
+		for _, v := range items {
+			if err := w.Write(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if actual := b.String(); actual != tt.expected {
+			t.Errorf("Expected %s, actual %s", tt.expected, actual)
+		}
+	}
+}
+
+var errFull = errors.New("disk full")
+
+type fullWriter struct{}
+
+func (fullWriter) Write([]byte) (int, error) {
+	return 0, errFull
+}
+
+func TestWriteError(t *testing.T) {
+	for _, w := range []run.Writer{
+		run.NewJSONWriter(fullWriter{}),
+		run.NewNDJSONWriter(fullWriter{}),
+		run.NewCSVWriter(fullWriter{}, nil),
+	} {
+		err := w.Write(items[0])
+		if err == nil {
+			err = w.Close()
+		}
+		if err != errFull {
+			t.Errorf("%T: expected %v, actual %v", w, errFull, err)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package tags
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/qedus/osmpbf"
+)
+
+// Element is an OSM element an Expr is evaluated against. Info is nil for
+// elements without metadata.
+type Element struct {
+	Type Type
+	Tags map[string]string
+	Info *osmpbf.Info
+}
+
+// Expr is a compiled tags filter.
+type Expr interface {
+	Eval(e *Element) bool
+}
+
+type orExpr []Expr
+
+func (x orExpr) Eval(e *Element) bool {
+	for _, x := range x {
+		if x.Eval(e) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpr []Expr
+
+func (x andExpr) Eval(e *Element) bool {
+	for _, x := range x {
+		if !x.Eval(e) {
+			return false
+		}
+	}
+	return true
+}
+
+type notExpr struct {
+	x Expr
+}
+
+func (x notExpr) Eval(e *Element) bool {
+	return !x.x.Eval(e)
+}
+
+// ParseExpr compiles a tags filter expression.
+//
+// A term is a key, optionally prefixed with element types as in Matcher,
+// followed by an optional operator:
+//
+//	highway                 key exists
+//	highway=*               key exists
+//	highway=primary         key has the value
+//	amenity=cafe,bar,pub    key has one of the values
+//	highway!=footway,path   key exists and has none of the values
+//	highway!=*              key is absent
+//	!highway                key is absent
+//	admin_level<=4          value is a number <= 4, also <, > and >=
+//	maxspeed<"30 mph"       numbers may have a unit, as values may
+//	highway~"^(primary|secondary)(_link)?$"
+//	                        value matches a regular expression
+//	name!~"(?i)^the "       key exists and its value does not match
+//	name:*                  a key matching a glob with * and ? exists
+//	@version=1              metadata, also @user, @uid and @changeset
+//	@timestamp>=2026-01-01  edited since, with a date or RFC 3339 time
+//
+// Comparisons read values as Matcher does: with a decimal point or comma, a
+// unit converted to OSM's default one, or a range matching if any value in
+// it does. Values that are not numbers do not match, so "not maxspeed<30"
+// matches maxspeed=none.
+//
+// Metadata pseudo-keys are absent for elements without metadata. @timestamp
+// is compared with <, <=, > and >= only, not with = and !=.
+//
+// Tag values are compared whole; SplitValues makes terms match values such
+// as "pizza;burger" by their parts.
+//
+// A term with a key glob holds if it does for a tag with a matching key;
+// "!=" and "!~" need it to for every such tag, of which there must be one.
+// Quoted keys are not globs.
+//
+// Values are compared case-sensitively. Unlike Matcher rules, which have
+// ignore_case, expressions fold case only in regular expressions, with
+// (?i) as in name~"(?i)^cafe$".
+//
+// Terms are combined with "and", "or", "not" (or "!") and parentheses. "not"
+// binds tighter than "and", which binds tighter than "or". Keys and values
+// containing spaces or special characters can be double-quoted. In quotes, a
+// backslash escapes a quote or a backslash and is kept before anything else,
+// so regular expressions need no double escaping.
+func ParseExpr(s string) (Expr, error) {
+	p := &parser{lexer: lexer{s: s}}
+	p.next()
+	x, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return x, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+	tokComma
+	tokNot
+	tokOp
+	tokError
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return fmt.Sprintf("%q", t.text)
+	}
+	return fmt.Sprintf("'%s'", t.text)
+}
+
+// operators lists operator tokens, longest first.
+var operators = []string{"!=", "!~", "<=", ">=", "=", "<", ">", "~"}
+
+type lexer struct {
+	s   string
+	pos int
+}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.s) && isSpace(l.s[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.s) {
+		return token{tokEOF, "", start}
+	}
+	for _, op := range operators {
+		if strings.HasPrefix(l.s[l.pos:], op) {
+			l.pos += len(op)
+			return token{tokOp, op, start}
+		}
+	}
+	switch c := l.s[l.pos]; c {
+	case '(':
+		l.pos++
+		return token{tokLParen, "(", start}
+	case ')':
+		l.pos++
+		return token{tokRParen, ")", start}
+	case ',':
+		l.pos++
+		return token{tokComma, ",", start}
+	case '!':
+		l.pos++
+		return token{tokNot, "!", start}
+	case '"':
+		return l.quoted()
+	}
+	for l.pos < len(l.s) && !isSpace(l.s[l.pos]) && !isSpecial(l.s[l.pos]) {
+		l.pos++
+	}
+	return token{tokWord, l.s[start:l.pos], start}
+}
+
+func (l *lexer) quoted() token {
+	start := l.pos
+	l.pos++
+	var b bytes.Buffer
+	for l.pos < len(l.s) {
+		c := l.s[l.pos]
+		l.pos++
+		switch c {
+		case '"':
+			return token{tokString, b.String(), start}
+		case '\\':
+			// escapes a quote or a backslash, and is kept before anything
+			// else for regular expressions
+			if l.pos < len(l.s) && (l.s[l.pos] == '"' || l.s[l.pos] == '\\') {
+				c = l.s[l.pos]
+				l.pos++
+			}
+		}
+		b.WriteByte(c)
+	}
+	return token{tokError, "unterminated string", start}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isSpecial(c byte) bool {
+	if strings.IndexByte("(),!\"", c) >= 0 {
+		return true
+	}
+	for _, op := range operators {
+		if op[0] == c {
+			return true
+		}
+	}
+	return false
+}
+
+type parser struct {
+	lexer
+	tok token
+}
+
+func (p *parser) next() {
+	p.tok = p.lexer.next()
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("expression at position %d: %s", p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+// keyword reports if the current token is the unquoted keyword kw.
+func (p *parser) keyword(kw string) bool {
+	return p.tok.kind == tokWord && strings.EqualFold(p.tok.text, kw)
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	xs := orExpr{x}
+	for p.keyword("or") {
+		p.next()
+		if x, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if len(xs) == 1 {
+		return x, nil
+	}
+	return xs, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	xs := andExpr{x}
+	for p.keyword("and") {
+		p.next()
+		if x, err = p.parseUnary(); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if len(xs) == 1 {
+		return x, nil
+	}
+	return xs, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	switch {
+	case p.tok.kind == tokNot, p.keyword("not"):
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x}, nil
+	case p.tok.kind == tokLParen:
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRParen {
+			return nil, p.errorf("expected ')', got %s", p.tok)
+		}
+		p.next()
+		return x, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	if p.tok.kind == tokError {
+		return nil, p.errorf("%s", p.tok.text)
+	}
+	if p.tok.kind != tokWord && p.tok.kind != tokString || p.keyword("and") || p.keyword("or") {
+		return nil, p.errorf("expected key, got %s", p.tok)
+	}
+	x := &rule{op: opExists}
+	if p.tok.kind == tokWord {
+		x.setKey(splitKey(p.tok.text))
+	} else {
+		x.types, x.key = Any, p.tok.text
+	}
+	if err := checkKey(x.key); err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.next()
+	if p.tok.kind != tokOp {
+		return x, nil
+	}
+	op := p.tok.text
+	p.next()
+	if c, ok := cmpOps[op]; ok {
+		n, err := p.parseNumber(x, op)
+		if err != nil {
+			return nil, err
+		}
+		x.op = opCompare
+		x.cmps = []comparison{{c, n}}
+		return x, nil
+	}
+	if op == "~" || op == "!~" {
+		if p.tok.kind == tokError {
+			return nil, p.errorf("%s", p.tok.text)
+		}
+		if p.tok.kind != tokWord && p.tok.kind != tokString {
+			return nil, p.errorf("expected regular expression after %s, got %s", op, p.tok)
+		}
+		pattern, err := regexp.Compile(p.tok.text)
+		if err != nil {
+			return nil, p.errorf("%s: %v", x.key, err)
+		}
+		p.next()
+		x.pattern = pattern
+		x.op = opMatch
+		if op == "!~" {
+			x.op = opNotMatch
+		}
+		return x, nil
+	}
+	if p.tok.kind == tokWord && p.tok.text == "*" {
+		p.next()
+		if op == "!=" {
+			x.op = opAbsent
+		}
+		return x, nil
+	}
+	values, err := p.parseValues()
+	if err != nil {
+		return nil, err
+	}
+	x.values = values
+	x.op = opEqual
+	if op == "!=" {
+		x.op = opNotEqual
+	}
+	if err := x.checkOp(); err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	return x, nil
+}
+
+// parseNumber parses the number compared with by op of x, a date for
+// @timestamp.
+func (p *parser) parseNumber(x *rule, op string) (float64, error) {
+	if p.tok.kind == tokError {
+		return 0, p.errorf("%s", p.tok.text)
+	}
+	if p.tok.kind == tokWord || p.tok.kind == tokString {
+		if lo, hi, ok := x.number(p.tok.text); ok && lo == hi {
+			p.next()
+			return lo, nil
+		}
+	}
+	if x.key == timestampKey {
+		return 0, p.errorf("expected date after %s, got %s", op, p.tok)
+	}
+	return 0, p.errorf("expected number after %s, got %s", op, p.tok)
+}
+
+func (p *parser) parseValues() ([]string, error) {
+	var values []string
+	for {
+		if p.tok.kind == tokError {
+			return nil, p.errorf("%s", p.tok.text)
+		}
+		if p.tok.kind != tokWord && p.tok.kind != tokString {
+			return nil, p.errorf("expected value, got %s", p.tok)
+		}
+		values = append(values, p.tok.text)
+		p.next()
+		if p.tok.kind != tokComma {
+			break
+		}
+		p.next()
+	}
+	sort.Strings(values)
+	return values, nil
+}
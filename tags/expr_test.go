@@ -0,0 +1,274 @@
+package tags_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ambiweb/osm-pbf-filter/tags"
+	"github.com/qedus/osmpbf"
+)
+
+var info = &osmpbf.Info{
+	Version:   3,
+	Timestamp: time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC),
+	Changeset: 170001,
+	Uid:       42,
+	User:      "mapper",
+}
+
+var exprTests = []struct {
+	expr     string
+	element  tags.Element
+	expected bool
+}{
+	{
+		"amenity",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"amenity": "cafe"}},
+		true,
+	},
+	{
+		"amenity=*",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"shop": "bakery"}},
+		false,
+	},
+	{
+		"amenity=cafe and cuisine=coffee_shop",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"amenity": "cafe", "cuisine": "coffee_shop"}},
+		true,
+	},
+	{
+		"amenity=cafe and cuisine=coffee_shop",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"amenity": "cafe"}},
+		false,
+	},
+	{
+		"amenity=cafe,bar,pub",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"amenity": "pub"}},
+		true,
+	},
+	{
+		"highway and not highway=footway",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"highway": "footway"}},
+		false,
+	},
+	{
+		"highway AND NOT highway=footway",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"highway": "primary"}},
+		true,
+	},
+	{
+		"highway!=footway,path",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"highway": "path"}},
+		false,
+	},
+	{
+		"highway!=footway,path",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"name": "Main Street"}},
+		false,
+	},
+	{
+		"building and highway!=*",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"building": "yes"}},
+		true,
+	},
+	{
+		"building and !highway",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"building": "yes", "highway": "service"}},
+		false,
+	},
+	{
+		"shop or amenity and cuisine",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"shop": "bakery"}},
+		true,
+	},
+	{
+		"(shop or amenity) and cuisine",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"shop": "bakery"}},
+		false,
+	},
+	{
+		"n/amenity=cafe",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"amenity": "cafe"}},
+		false,
+	},
+	{
+		"wr/building",
+		tags.Element{Type: tags.Relation, Tags: map[string]string{"building": "yes"}},
+		true,
+	},
+	{
+		"admin_level<=4",
+		tags.Element{Type: tags.Relation, Tags: map[string]string{"admin_level": "4"}},
+		true,
+	},
+	{
+		"admin_level<4",
+		tags.Element{Type: tags.Relation, Tags: map[string]string{"admin_level": "4"}},
+		false,
+	},
+	{
+		"ele>2000",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"ele": "2962,06"}},
+		true,
+	},
+	{
+		"ele>2000",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"ele": "7000 ft"}},
+		true,
+	},
+	{
+		`maxspeed<"30 mph"`,
+		tags.Element{Type: tags.Way, Tags: map[string]string{"maxspeed": "50"}},
+		false,
+	},
+	{
+		"building:levels>=4",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"building:levels": "2-4"}},
+		true,
+	},
+	{
+		"maxspeed<30 or not maxspeed<30",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"maxspeed": "walk"}},
+		true,
+	},
+	{
+		"maxspeed<30",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"maxspeed": "walk"}},
+		false,
+	},
+	{
+		`name="Caf\"e Central"`,
+		tags.Element{Type: tags.Node, Tags: map[string]string{"name": `Caf"e Central`}},
+		true,
+	},
+	{
+		`highway~"^(primary|secondary)(_link)?$"`,
+		tags.Element{Type: tags.Way, Tags: map[string]string{"highway": "secondary_link"}},
+		true,
+	},
+	{
+		`highway~"^(primary|secondary)(_link)?$"`,
+		tags.Element{Type: tags.Way, Tags: map[string]string{"highway": "tertiary"}},
+		false,
+	},
+	{
+		`name!~"(?i)^the "`,
+		tags.Element{Type: tags.Node, Tags: map[string]string{"name": "The Anchor"}},
+		false,
+	},
+	{
+		`ref~"^\d+$"`,
+		tags.Element{Type: tags.Way, Tags: map[string]string{"ref": "42"}},
+		true,
+	},
+	{
+		"addr:*",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"addr:street": "Main Street"}},
+		true,
+	},
+	{
+		"name:??=Wien",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"name": "Vienna", "name:de": "Wien"}},
+		true,
+	},
+	{
+		"name:*!=*",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"name": "Wien", "name:de": "Wien"}},
+		false,
+	},
+	{
+		"name:*!~^[a-z]",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"name:de": "Wien", "name:fr": "vienne"}},
+		false,
+	},
+	{
+		"@version=1 or @uid=42,43",
+		tags.Element{Type: tags.Node, Info: info},
+		true,
+	},
+	{
+		"building and @timestamp>=2026-01-01 and @user!=mapper",
+		tags.Element{Type: tags.Way, Tags: map[string]string{"building": "yes"}, Info: info},
+		false,
+	},
+	{
+		`@timestamp<"2026-02-01T13:00:00Z" and @changeset>170000`,
+		tags.Element{Type: tags.Node, Info: info},
+		true,
+	},
+	{
+		"@user",
+		tags.Element{Type: tags.Node, Tags: map[string]string{"@user": "mapper"}},
+		false,
+	},
+	{
+		`"addr:*"`,
+		tags.Element{Type: tags.Node, Tags: map[string]string{"addr:street": "Main Street"}},
+		false,
+	},
+}
+
+func TestParseExpr(t *testing.T) {
+	for _, tt := range exprTests {
+		x, err := tags.ParseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.expr, err)
+			continue
+		}
+		if actual := x.Eval(&tt.element); actual != tt.expected {
+			t.Errorf("%s: expected %v, actual %v", tt.expr, tt.expected, actual)
+		}
+	}
+}
+
+var exprErrorTests = []string{
+	"",
+	"amenity=",
+	"(amenity",
+	"amenity and",
+	`name="unterminated`,
+	"amenity cafe",
+	"admin_level<=",
+	"admin_level<=high",
+	"admin_level>=2-4",
+	"highway~",
+	"highway~(primary",
+	"@editor=mapper",
+	"@timestamp>yesterday",
+	"@timestamp=2026-01-01",
+	"@timestamp!=2026-01-01",
+}
+
+func TestParseExprError(t *testing.T) {
+	for _, s := range exprErrorTests {
+		if _, err := tags.ParseExpr(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+var splitTests = []struct {
+	expr     string
+	tags     map[string]string
+	expected bool
+}{
+	{"cuisine=pizza", map[string]string{"cuisine": "pizza;burger"}, true},
+	{"cuisine!=pizza", map[string]string{"cuisine": "burger;pizza"}, false},
+	{"cuisine!=pizza", map[string]string{"cuisine": "burger;kebab"}, true},
+	{"not amenity~^bar$", map[string]string{"amenity": "bar;restaurant"}, false},
+	{"maxspeed>=50", map[string]string{"maxspeed": "30 ; 60"}, true},
+}
+
+func TestSplitValues(t *testing.T) {
+	for _, tt := range splitTests {
+		x, err := tags.ParseExpr(tt.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.expr, err)
+			continue
+		}
+		tags.SplitValues(x)
+		if actual := x.Eval(&tags.Element{Type: tags.Node, Tags: tt.tags}); actual != tt.expected {
+			t.Errorf("%s: expected %v, actual %v", tt.expr, tt.expected, actual)
+		}
+	}
+}
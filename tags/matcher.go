@@ -1,31 +1,376 @@
 package tags
 
-import "sort"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
 
-// Matcher represents a tags structure to match.
-type Matcher map[string]interface{}
+// Type is a set of OSM element types a rule applies to.
+type Type uint8
 
-// Match checks if tags match.
-func (m Matcher) Match(tags map[string]string) bool {
-	for k, v := range m {
+// Element types. Any matches every element type.
+const (
+	Node Type = 1 << iota
+	Way
+	Relation
+
+	Any = Node | Way | Relation
+)
+
+// Matcher is a compiled set of tag rules. Tags match if any rule matches.
+type Matcher struct {
+	rules []*rule
+}
+
+// NewMatcher compiles rules given as a map of keys to values. A value is
+// either a bool telling if the key must exist, a string the tag must be equal
+// to, a list of strings the tag must be one of or a map of operators:
+//
+//	{lte: 4}                     a number <= 4, also lt, gt and gte
+//	{gte: 1000, lt: 5000}        comparisons must all hold
+//	{eq: [cafe, bar]}            one of the values, as a list is
+//	{glob: "*_link"}             a glob with * and ?
+//	{regex: "^(primary|secondary)(_link)?$"}
+//	{eq: Cafe, ignore_case: yes} eq, glob and regex fold case with ignore_case
+//	{eq: pizza, split: yes}      any of the values separated by ";" matches
+//
+// With split, a tag value such as "pizza;burger" is split on semicolons,
+// with ";;" escaping one, and matches if any trimmed part does; negated
+// rules match if none does. SplitValues sets it for every rule.
+//
+// Comparisons read tag values as parseNumber does; values that do not parse
+// as a number do not match. Patterns are compiled here, so invalid ones are
+// reported with their rule.
+//
+// A key may be prefixed with a combination of "n", "w" and "r" followed by a
+// slash to limit the rule to nodes, ways and relations, the way osmium does,
+// e.g. "n/amenity" or "wr/boundary". A key without a prefix applies to every
+// element type. A key with * or ? is a glob matching keys, e.g. "name:*";
+// the rule matches if any tag with a matching key does.
+//
+// The keys @user, @uid, @changeset, @version and @timestamp match metadata
+// of elements, which is absent for elements without it. Their numbers need
+// no quotes, e.g. "@uid: [123, 456]". @timestamp compares dates with lt,
+// lte, gt and gte only, e.g. "@timestamp: {gte: 2026-01-01}".
+func NewMatcher(rules map[string]interface{}) (*Matcher, error) {
+	keys := make([]string, 0, len(rules))
+	for k := range rules {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m := &Matcher{}
+	for _, k := range keys {
+		r := &rule{}
+		r.setKey(splitKey(k))
+		if err := checkKey(r.key); err != nil {
+			return nil, fmt.Errorf("tags: %s: %v", k, err)
+		}
+		v := rules[k]
+		if _, ok := metaKeys[r.key]; ok {
+			v = metaValue(v)
+		}
 		switch v := v.(type) {
 		case bool:
-			if _, ok := tags[k]; ok && v {
-				return true
+			if !v {
+				continue
 			}
+			r.op = opExists
+		case string:
+			r.op = opEqual
+			r.values = []string{v}
 		case []string:
-			if tag, ok := tags[k]; ok {
-				sort.Strings(v)
-				i := sort.Search(len(v), func(i int) bool { return v[i] >= tag })
-				if i < len(v) && v[i] == tag {
-					return true
-				}
+			r.op = opEqual
+			r.values = append([]string(nil), v...)
+		case map[interface{}]interface{}, map[string]interface{}:
+			if err := r.setOperators(v); err != nil {
+				return nil, fmt.Errorf("tags: %s: %v", k, err)
 			}
-		case string:
-			if tag, ok := tags[k]; ok && v == tag {
-				return true
+		case []interface{}:
+			values, err := stringList(v)
+			if err != nil {
+				return nil, fmt.Errorf("tags: %s: %v", k, err)
 			}
+			r.op = opEqual
+			r.values = values
+		default:
+			return nil, fmt.Errorf("tags: %s: unsupported value: %s", k, describe(v))
+		}
+		if err := r.checkOp(); err != nil {
+			return nil, fmt.Errorf("tags: %s: %v", k, err)
+		}
+		r.compile()
+		m.rules = append(m.rules, r)
+	}
+	return m, nil
+}
+
+// UnmarshalYAML compiles a Matcher from a YAML map of rules.
+func (m *Matcher) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var rules map[string]interface{}
+	if err := unmarshal(&rules); err != nil {
+		return err
+	}
+	compiled, err := NewMatcher(rules)
+	if err != nil {
+		return err
+	}
+	*m = *compiled
+	return nil
+}
+
+// Match checks if tags match.
+func (m *Matcher) Match(tags map[string]string) bool {
+	return m.MatchType(Any, tags)
+}
+
+// MatchType checks if tags of an element of type t match.
+func (m *Matcher) MatchType(t Type, tags map[string]string) bool {
+	return m.Eval(&Element{Type: t, Tags: tags})
+}
+
+// Eval makes Matcher an Expr. A Matcher is an OR of its rules.
+func (m *Matcher) Eval(e *Element) bool {
+	for _, r := range m.rules {
+		if r.Eval(e) {
+			return true
 		}
 	}
 	return false
 }
+
+// SplitValues sets the split mode of NewMatcher on every rule of x, a
+// Matcher or an expression.
+func SplitValues(x Expr) {
+	switch x := x.(type) {
+	case *Matcher:
+		for _, r := range x.rules {
+			r.split = true
+		}
+	case *rule:
+		x.split = true
+	case orExpr:
+		for _, x := range x {
+			SplitValues(x)
+		}
+	case andExpr:
+		for _, x := range x {
+			SplitValues(x)
+		}
+	case notExpr:
+		SplitValues(x.x)
+	}
+}
+
+// setOperators sets the operators of a rule from a map of operator names
+// to operands.
+func (r *rule) setOperators(v interface{}) error {
+	ops := make(map[string]interface{})
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for op, n := range v {
+			ops[fmt.Sprint(op)] = n
+		}
+	case map[string]interface{}:
+		ops = v
+	}
+	for name, flag := range map[string]*bool{"ignore_case": &r.fold, "split": &r.split} {
+		v, ok := ops[name]
+		if !ok {
+			continue
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("%s: expected yes or no, got %s", name, describe(v))
+		}
+		*flag = b
+		delete(ops, name)
+	}
+	names := make([]string, 0, len(ops))
+	for op := range ops {
+		names = append(names, op)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return errors.New("no operator")
+	}
+	for _, name := range names {
+		operand := ops[name]
+		if c, ok := cmpNames[name]; ok {
+			n, err := r.operand(operand)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			r.cmps = append(r.cmps, comparison{c, n})
+			continue
+		}
+		if len(names) > 1 {
+			return fmt.Errorf("%s can not be combined with %s", name, other(names, name))
+		}
+		var err error
+		switch name {
+		case "eq":
+			r.op = opEqual
+			switch v := operand.(type) {
+			case string:
+				r.values = []string{v}
+			case []interface{}:
+				r.values, err = stringList(v)
+			default:
+				err = fmt.Errorf("unsupported value: %s", describe(v))
+			}
+		case "glob", "regex":
+			s, ok := operand.(string)
+			if !ok {
+				return fmt.Errorf("%s: expected a string, got %s", name, describe(operand))
+			}
+			r.op = opMatch
+			if name == "glob" {
+				s = globRegexp(s)
+			}
+			r.pattern, err = compilePattern(s, r.fold)
+		default:
+			return fmt.Errorf("unknown operator %s, expected lt, lte, gt, gte, eq, glob or regex", name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if len(r.cmps) > 0 {
+		if r.fold {
+			return errors.New("ignore_case can not be combined with comparisons")
+		}
+		r.op = opCompare
+	}
+	return nil
+}
+
+// other returns the first of names which is not name.
+func other(names []string, name string) string {
+	for _, n := range names {
+		if n != name {
+			return n
+		}
+	}
+	return ""
+}
+
+// stringList converts a list of strings.
+func stringList(v []interface{}) ([]string, error) {
+	values := make([]string, len(v))
+	for i, v := range v {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("item %d: unsupported value: %s", i+1, describe(v))
+		}
+		values[i] = s
+	}
+	return values, nil
+}
+
+// metaValue converts numbers in the value of a metadata rule, such as
+// "@uid: [123, 456]", to strings.
+func metaValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int, int64, uint64:
+		return fmt.Sprint(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, v := range v {
+			values[i] = metaValue(v)
+		}
+		return values
+	case map[interface{}]interface{}:
+		ops := make(map[interface{}]interface{}, len(v))
+		for op, v := range v {
+			ops[op] = v
+			if op == "eq" {
+				ops[op] = metaValue(v)
+			}
+		}
+		return ops
+	case map[string]interface{}:
+		ops := make(map[string]interface{}, len(v))
+		for op, v := range v {
+			ops[op] = v
+			if op == "eq" {
+				ops[op] = metaValue(v)
+			}
+		}
+		return ops
+	}
+	return v
+}
+
+// operand converts the operand of a comparison of the rule, a date for
+// @timestamp.
+func (r *rule) operand(v interface{}) (float64, error) {
+	if r.key != timestampKey {
+		return number(v)
+	}
+	if s, ok := v.(string); ok {
+		if t, ok := parseTime(s); ok {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("%v is not a date", v)
+}
+
+// number converts a number of a rule.
+func number(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		lo, hi, ok := parseNumber(v)
+		if ok && lo == hi {
+			return lo, nil
+		}
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+// splitKey separates an optional element type prefix from a rule key.
+func splitKey(k string) (Type, string) {
+	i := strings.IndexByte(k, '/')
+	if i < 1 {
+		return Any, k
+	}
+	var types Type
+	for _, c := range k[:i] {
+		switch c {
+		case 'n':
+			types |= Node
+		case 'w':
+			types |= Way
+		case 'r':
+			types |= Relation
+		default:
+			return Any, k
+		}
+	}
+	return types, k[i+1:]
+}
+
+// describe names the kind of an unsupported rule value for error messages.
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case int, int64, uint64, float64:
+		return fmt.Sprintf("%v (a number, quote it to match a string)", v)
+	case map[interface{}]interface{}, map[string]interface{}:
+		return "a nested map"
+	case []interface{}:
+		return "a nested list"
+	}
+	return fmt.Sprintf("%v of type %T", v, v)
+}
@@ -1,43 +1,46 @@
 package tags_test
 
 import (
+	"strings"
 	"testing"
 
+	yaml "gopkg.in/yaml.v2"
+
 	"github.com/ambiweb/osm-pbf-filter/tags"
 )
 
 var matchTests = []struct {
-	matcher  tags.Matcher
+	rules    map[string]interface{}
 	tags     map[string]string
 	expected bool
 }{
 	{
-		tags.Matcher(map[string]interface{}{"tag": true}),
+		map[string]interface{}{"tag": true},
 		map[string]string{"tag": ""},
 		true,
 	},
 	{
-		tags.Matcher(map[string]interface{}{"tag": false}),
+		map[string]interface{}{"tag": false},
 		map[string]string{"tag": ""},
 		false,
 	},
 	{
-		tags.Matcher(map[string]interface{}{"tag": []string{"value"}}),
+		map[string]interface{}{"tag": []string{"value"}},
 		map[string]string{"tag": "value"},
 		true,
 	},
 	{
-		tags.Matcher(map[string]interface{}{"tag": []string{"value"}}),
+		map[string]interface{}{"tag": []string{"value"}},
 		map[string]string{"tag": "another value"},
 		false,
 	},
 	{
-		tags.Matcher(map[string]interface{}{"tag": "value"}),
+		map[string]interface{}{"tag": "value"},
 		map[string]string{"tag": "value"},
 		true,
 	},
 	{
-		tags.Matcher(map[string]interface{}{"tag": "value"}),
+		map[string]interface{}{"tag": "value"},
 		map[string]string{"tag": "another value"},
 		false,
 	},
@@ -45,8 +48,266 @@ var matchTests = []struct {
 
 func TestMatch(t *testing.T) {
 	for _, tt := range matchTests {
-		if actual := tt.matcher.Match(tt.tags); actual != tt.expected {
+		m, err := tags.NewMatcher(tt.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := m.Match(tt.tags); actual != tt.expected {
+			t.Errorf("Expected %v, actual %v", tt.expected, actual)
+		}
+	}
+}
+
+var matchTypeTests = []struct {
+	rules    map[string]interface{}
+	typ      tags.Type
+	tags     map[string]string
+	expected bool
+}{
+	{
+		map[string]interface{}{"tag": "value"},
+		tags.Node,
+		map[string]string{"tag": "value"},
+		true,
+	},
+	{
+		map[string]interface{}{"n/tag": "value"},
+		tags.Node,
+		map[string]string{"tag": "value"},
+		true,
+	},
+	{
+		map[string]interface{}{"n/tag": "value"},
+		tags.Way,
+		map[string]string{"tag": "value"},
+		false,
+	},
+	{
+		map[string]interface{}{"wr/tag": true},
+		tags.Relation,
+		map[string]string{"tag": ""},
+		true,
+	},
+	{
+		map[string]interface{}{"wr/tag": true},
+		tags.Node,
+		map[string]string{"tag": ""},
+		false,
+	},
+	{
+		map[string]interface{}{"a/b": "value"},
+		tags.Way,
+		map[string]string{"a/b": "value"},
+		true,
+	},
+}
+
+func TestMatchType(t *testing.T) {
+	for _, tt := range matchTypeTests {
+		m, err := tags.NewMatcher(tt.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := m.MatchType(tt.typ, tt.tags); actual != tt.expected {
 			t.Errorf("Expected %v, actual %v", tt.expected, actual)
 		}
 	}
 }
+
+var yamlTests = []struct {
+	yaml     string
+	tags     map[string]string
+	expected bool
+}{
+	{
+		"place: [continent, country, city]",
+		map[string]string{"place": "city"},
+		true,
+	},
+	{
+		"place: [continent, country, city]",
+		map[string]string{"place": "village"},
+		false,
+	},
+	{
+		"boundary: yes",
+		map[string]string{"boundary": "administrative"},
+		true,
+	},
+	{
+		"admin_level: '4'",
+		map[string]string{"admin_level": "4"},
+		true,
+	},
+	{
+		"admin_level: {lte: 4}",
+		map[string]string{"admin_level": "2"},
+		true,
+	},
+	{
+		"admin_level: {lte: 4}",
+		map[string]string{"admin_level": "8"},
+		false,
+	},
+	{
+		"population: {gte: 100000, lt: 1000000}",
+		map[string]string{"population": "3645000"},
+		false,
+	},
+	{
+		"maxspeed: {lt: 50}",
+		map[string]string{"maxspeed": "30 mph"},
+		true,
+	},
+	{
+		"maxspeed: {lt: 30 mph}",
+		map[string]string{"maxspeed": "50"},
+		false,
+	},
+	{
+		"maxspeed: {lt: 50}",
+		map[string]string{"maxspeed": "none"},
+		false,
+	},
+	{
+		`highway: {regex: "^(primary|secondary)(_link)?$"}`,
+		map[string]string{"highway": "primary_link"},
+		true,
+	},
+	{
+		"highway: {glob: '*_link'}",
+		map[string]string{"highway": "motorway"},
+		false,
+	},
+	{
+		"name: {glob: 'caf?*', ignore_case: yes}",
+		map[string]string{"name": "Café Central"},
+		true,
+	},
+	{
+		"name: {eq: [Cafe, Bar], ignore_case: yes}",
+		map[string]string{"name": "CAFE"},
+		true,
+	},
+	{
+		"name: {eq: Cafe}",
+		map[string]string{"name": "CAFE"},
+		false,
+	},
+	{
+		"name:*: Wien",
+		map[string]string{"name:de": "Wien"},
+		true,
+	},
+	{
+		"name:*: yes",
+		map[string]string{"name": "Wien"},
+		false,
+	},
+	{
+		"cuisine: [pizza]",
+		map[string]string{"cuisine": "pizza;burger"},
+		false,
+	},
+	{
+		"cuisine: {eq: [pizza], split: yes}",
+		map[string]string{"cuisine": "burger; pizza"},
+		true,
+	},
+	{
+		"name: {eq: 'a;b', split: yes}",
+		map[string]string{"name": "a;;b;c"},
+		true,
+	},
+	{
+		"name: {eq: a, split: yes}",
+		map[string]string{"name": "a;;b;c"},
+		false,
+	},
+	{
+		"amenity: {regex: ^rest, split: yes}",
+		map[string]string{"amenity": "bar;restaurant"},
+		true,
+	},
+	{
+		"maxspeed: {lt: 40, split: yes}",
+		map[string]string{"maxspeed": "50;30"},
+		true,
+	},
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	for _, tt := range yamlTests {
+		var m tags.Matcher
+		if err := yaml.Unmarshal([]byte(tt.yaml), &m); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.yaml, err)
+			continue
+		}
+		if actual := m.Match(tt.tags); actual != tt.expected {
+			t.Errorf("%s: expected %v, actual %v", tt.yaml, tt.expected, actual)
+		}
+	}
+}
+
+var yamlInfoTests = []struct {
+	yaml     string
+	expected bool
+}{
+	{"'@uid': [41, 42]", true},
+	{"'@version': 1", false},
+	{"'@changeset': {gte: 170000, lt: 180000}", true},
+	{"'@timestamp': {gte: 2026-01-01}", true},
+	{"'@timestamp': {lt: '2026-02-01 11:00:00'}", false},
+	{"'@user': {glob: Map*, ignore_case: yes}", true},
+}
+
+func TestUnmarshalYAMLInfo(t *testing.T) {
+	for _, tt := range yamlInfoTests {
+		var m tags.Matcher
+		if err := yaml.Unmarshal([]byte(tt.yaml), &m); err != nil {
+			t.Errorf("%s: unexpected error %v", tt.yaml, err)
+			continue
+		}
+		if actual := m.Eval(&tags.Element{Type: tags.Node, Info: info}); actual != tt.expected {
+			t.Errorf("%s: expected %v, actual %v", tt.yaml, tt.expected, actual)
+		}
+	}
+}
+
+var yamlErrorTests = []struct {
+	yaml string
+	key  string
+}{
+	{"admin_level: 4", "admin_level"},
+	{"place: [city, 4]", "place"},
+	{"place: {city: yes}", "place"},
+	{"ele: {gt: high}", "ele"},
+	{"ele: {}", "ele"},
+	{"place: [[city]]", "place"},
+	{"place:", "place"},
+	{"highway: {regex: '(primary'}", "highway"},
+	{"highway: {regex: primary, eq: primary}", "highway"},
+	{"highway: {glob: [primary]}", "highway"},
+	{"name: {eq: Cafe, ignore_case: 1}", "name"},
+	{"cuisine: {split: yes}", "cuisine"},
+	{"ele: {gt: 100, ignore_case: yes}", "ele"},
+	{"'@uid': {gt: yesterday}", "@uid"},
+	{"'@timestamp': {gte: 2026}", "@timestamp"},
+	{"'@id': 1", "@id"},
+	{"'@timestamp': 2026-01-01", "@timestamp"},
+	{"'@timestamp': {eq: [2026-01-01]}", "@timestamp"},
+}
+
+func TestUnmarshalYAMLError(t *testing.T) {
+	for _, tt := range yamlErrorTests {
+		var m tags.Matcher
+		err := yaml.Unmarshal([]byte(tt.yaml), &m)
+		if err == nil {
+			t.Errorf("%s: expected error", tt.yaml)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.key) {
+			t.Errorf("%s: expected error naming %s, actual %v", tt.yaml, tt.key, err)
+		}
+	}
+}
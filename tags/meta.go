@@ -0,0 +1,82 @@
+package tags
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qedus/osmpbf"
+)
+
+// timestampKey is the metadata pseudo-key compared as a date.
+const timestampKey = "@timestamp"
+
+// metaKeys maps pseudo-keys to the metadata of elements they read. Rules
+// treat them like tags.
+var metaKeys = map[string]func(*osmpbf.Info) string{
+	"@user": func(i *osmpbf.Info) string {
+		return i.User
+	},
+	"@uid": func(i *osmpbf.Info) string {
+		return strconv.FormatInt(int64(i.Uid), 10)
+	},
+	"@changeset": func(i *osmpbf.Info) string {
+		return strconv.FormatInt(i.Changeset, 10)
+	},
+	"@version": func(i *osmpbf.Info) string {
+		return strconv.FormatInt(int64(i.Version), 10)
+	},
+	timestampKey: func(i *osmpbf.Info) string {
+		return i.Timestamp.UTC().Format(time.RFC3339)
+	},
+}
+
+// value returns the value of a tag or of a metadata pseudo-key of e.
+func (e *Element) value(key string) (string, bool) {
+	if meta, ok := metaKeys[key]; ok {
+		if e.Info == nil {
+			return "", false
+		}
+		return meta(e.Info), true
+	}
+	v, ok := e.Tags[key]
+	return v, ok
+}
+
+// checkKey reports keys starting with @ which are not metadata pseudo-keys.
+func checkKey(key string) error {
+	if _, ok := metaKeys[key]; ok || !strings.HasPrefix(key, "@") {
+		return nil
+	}
+	return fmt.Errorf("unknown metadata key %s, expected @user, @uid, @changeset, @version or @timestamp", key)
+}
+
+// checkOp reports equality with @timestamp, which would compare the RFC 3339
+// time of elements with a date as strings.
+func (r *rule) checkOp() error {
+	if r.key == timestampKey && (r.op == opEqual || r.op == opNotEqual) {
+		return fmt.Errorf("%s can not be equal to a value, compare it with <, <=, > or >=", timestampKey)
+	}
+	return nil
+}
+
+// timeLayouts are the layouts of dates compared with @timestamp, in UTC
+// unless they have a zone.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseTime parses a date as seconds since the epoch.
+func parseTime(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return float64(t.Unix()), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,88 @@
+package tags
+
+import (
+	"strconv"
+	"strings"
+)
+
+// units converts values with a unit to the default unit OSM assumes for
+// values without one: metres, km/h and tonnes.
+var units = map[string]float64{
+	"":      1,
+	"m":     1,
+	"km":    1000,
+	"cm":    0.01,
+	"mm":    0.001,
+	"mi":    1609.344,
+	"ft":    0.3048,
+	"'":     0.3048,
+	"km/h":  1,
+	"kmh":   1,
+	"kph":   1,
+	"mph":   1.609344,
+	"knots": 1.852,
+	"t":     1,
+	"kg":    0.001,
+	"%":     1,
+}
+
+// parseNumber parses a tag value as a number the way OSM writes them: with a
+// decimal point or comma and an optional unit, e.g. "2,5", "30 mph" or
+// "3.5 t". A range such as "2-4" gives its bounds, a single number the same
+// value twice. Values with a unit are converted to OSM's default unit. A
+// comma is always decimal, so "1,000" is 1. Other values, e.g. "none",
+// "signals" or "1,000.5", do not parse.
+func parseNumber(s string) (lo, hi float64, ok bool) {
+	s = strings.TrimSpace(s)
+	lo, rest, ok := leadingNumber(s)
+	if !ok {
+		return 0, 0, false
+	}
+	hi = lo
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, "-") {
+		if v, after, ok := leadingNumber(strings.TrimSpace(rest[1:])); ok {
+			hi, rest = v, after
+		}
+	}
+	f, ok := units[strings.ToLower(strings.TrimSpace(rest))]
+	if !ok {
+		return 0, 0, false
+	}
+	lo, hi = lo*f, hi*f
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	return lo, hi, true
+}
+
+// leadingNumber parses the number s starts with: an optional sign, digits
+// and optional decimals after a point or comma.
+func leadingNumber(s string) (v float64, rest string, ok bool) {
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	digits := 0
+	for ; i < len(s) && isDigit(s[i]); i++ {
+		digits++
+	}
+	if i+1 < len(s) && (s[i] == '.' || s[i] == ',') && isDigit(s[i+1]) {
+		i++
+		for ; i < len(s) && isDigit(s[i]); i++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, s, false
+	}
+	v, err := strconv.ParseFloat(strings.Replace(s[:i], ",", ".", 1), 64)
+	if err != nil {
+		return 0, s, false
+	}
+	return v, s[i:], true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,237 @@
+package tags
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type ruleOp int
+
+const (
+	opExists ruleOp = iota
+	opAbsent
+	opEqual
+	opNotEqual
+	opCompare
+	opMatch
+	opNotMatch
+)
+
+// rule tests a single key of an element. values are sorted, and lower case
+// if fold is set.
+type rule struct {
+	types Type
+	key   string
+	// keys matched by a key glob, nil for a plain key
+	keys   *regexp.Regexp
+	op     ruleOp
+	values []string
+	// comparisons of opCompare, which must all hold
+	cmps []comparison
+	// pattern of opMatch and opNotMatch
+	pattern *regexp.Regexp
+	fold    bool
+	// split values on semicolons, matching if any part does
+	split bool
+}
+
+// setKey sets the key of a rule, which is a glob if it has * or ?.
+func (r *rule) setKey(types Type, key string) {
+	r.types, r.key = types, key
+	if strings.ContainsAny(key, "*?") {
+		r.keys = regexp.MustCompile(globRegexp(key))
+	}
+}
+
+// compile prepares the values of a rule for Eval.
+func (r *rule) compile() {
+	if r.fold {
+		for i, v := range r.values {
+			r.values[i] = strings.ToLower(v)
+		}
+	}
+	sort.Strings(r.values)
+}
+
+func (r *rule) Eval(e *Element) bool {
+	if r.types&e.Type == 0 {
+		return false
+	}
+	if r.keys == nil {
+		tag, ok := e.value(r.key)
+		if r.op == opAbsent || !ok {
+			return r.op == opAbsent && !ok
+		}
+		return r.evalValue(tag)
+	}
+	// A glob matches if a tag with a matching key does, but negations need
+	// every such tag to.
+	negated := r.negated()
+	found := false
+	for k, tag := range e.Tags {
+		if !r.keys.MatchString(k) {
+			continue
+		}
+		found = true
+		switch {
+		case r.op == opAbsent:
+			return false
+		case negated && !r.evalValue(tag):
+			return false
+		case !negated && r.evalValue(tag):
+			return true
+		}
+	}
+	return r.op == opAbsent || negated && found
+}
+
+// evalValue tests the value of a tag with a key of the rule. Negated
+// operators hold if the positive one does not.
+func (r *rule) evalValue(tag string) bool {
+	negated := r.negated()
+	if !r.split {
+		return r.matchValue(tag) != negated
+	}
+	for _, part := range splitValue(tag) {
+		if r.matchValue(part) {
+			return !negated
+		}
+	}
+	return negated
+}
+
+func (r *rule) negated() bool {
+	return r.op == opNotEqual || r.op == opNotMatch
+}
+
+// matchValue tests a value with the positive operator of the rule.
+func (r *rule) matchValue(tag string) bool {
+	switch r.op {
+	case opEqual, opNotEqual:
+		return r.contains(tag)
+	case opMatch, opNotMatch:
+		return r.pattern.MatchString(tag)
+	case opCompare:
+		lo, hi, ok := r.number(tag)
+		if !ok {
+			return false
+		}
+		for _, c := range r.cmps {
+			if !c.holds(lo, hi) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// number reads a value compared by the rule: a date for @timestamp, a
+// number otherwise.
+func (r *rule) number(tag string) (lo, hi float64, ok bool) {
+	if r.key == timestampKey {
+		t, ok := parseTime(tag)
+		return t, t, ok
+	}
+	return parseNumber(tag)
+}
+
+// splitValue splits a tag value holding several values, such as
+// "pizza;burger", on semicolons. Parts are trimmed and ";;" is a semicolon
+// within a part.
+func splitValue(tag string) []string {
+	var parts []string
+	var part []byte
+	for i := 0; i < len(tag); i++ {
+		if tag[i] != ';' {
+			part = append(part, tag[i])
+			continue
+		}
+		if i+1 < len(tag) && tag[i+1] == ';' {
+			part = append(part, ';')
+			i++
+			continue
+		}
+		parts = append(parts, strings.TrimSpace(string(part)))
+		part = part[:0]
+	}
+	return append(parts, strings.TrimSpace(string(part)))
+}
+
+func (r *rule) contains(tag string) bool {
+	if r.fold {
+		tag = strings.ToLower(tag)
+	}
+	i := sort.SearchStrings(r.values, tag)
+	return i < len(r.values) && r.values[i] == tag
+}
+
+// globRegexp converts a glob, where * matches any text and ? any character,
+// to a regular expression matching whole strings.
+func globRegexp(glob string) string {
+	var b []string
+	for _, part := range strings.Split(glob, "*") {
+		var q []string
+		for _, s := range strings.Split(part, "?") {
+			q = append(q, regexp.QuoteMeta(s))
+		}
+		b = append(b, strings.Join(q, "."))
+	}
+	return "^" + strings.Join(b, ".*") + "$"
+}
+
+// compilePattern compiles a regular expression, ignoring case if fold is
+// set.
+func compilePattern(expr string, fold bool) (*regexp.Regexp, error) {
+	if fold {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+type cmpOp int
+
+const (
+	cmpLess cmpOp = iota
+	cmpLessEqual
+	cmpGreater
+	cmpGreaterEqual
+)
+
+// cmpOps maps expression operators to comparisons.
+var cmpOps = map[string]cmpOp{
+	"<":  cmpLess,
+	"<=": cmpLessEqual,
+	">":  cmpGreater,
+	">=": cmpGreaterEqual,
+}
+
+// cmpNames maps comparison names of Matcher rules to comparisons.
+var cmpNames = map[string]cmpOp{
+	"lt":  cmpLess,
+	"lte": cmpLessEqual,
+	"gt":  cmpGreater,
+	"gte": cmpGreaterEqual,
+}
+
+// comparison compares a numeric tag value with n.
+type comparison struct {
+	op cmpOp
+	n  float64
+}
+
+// holds reports if a value from lo to hi compares. A range holds if any
+// value in it does, so "2-4" is both <= 2 and >= 4.
+func (c comparison) holds(lo, hi float64) bool {
+	switch c.op {
+	case cmpLess:
+		return lo < c.n
+	case cmpLessEqual:
+		return lo <= c.n
+	case cmpGreater:
+		return hi > c.n
+	case cmpGreaterEqual:
+		return hi >= c.n
+	}
+	return false
+}